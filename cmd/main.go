@@ -2,6 +2,7 @@ package main
 
 import (
 	"elab-backend/handler"
+	"elab-backend/job"
 	"elab-backend/model"
 	"elab-backend/service"
 	"elab-backend/util/config"
@@ -14,6 +15,7 @@ func main() {
 	config.Load()
 	service.Init()
 	model.Init()
+	job.Init()
 	r := handler.Init()
 	err := r.Run(":2333")
 	if err != nil {
@@ -0,0 +1,22 @@
+package attachment
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/attachment")
+	route.GET("", GetAttachmentList)
+}
+
+func GetAttachmentList(ctx *gin.Context) {
+	var query apply.AttachmentQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.GetAttachmentList(ctx, query.OpenId, query.QuestionId))
+}
@@ -0,0 +1,85 @@
+package group
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/group")
+	route.GET("", GetGroupList)
+	route.POST("", CreateGroup)
+	route.PUT("/:id", UpdateGroup)
+	route.DELETE("/:id", DeleteGroup)
+}
+
+func GetGroupList(ctx *gin.Context) {
+	ctx.JSON(200, apply.GetGroupList(ctx))
+}
+
+func CreateGroup(ctx *gin.Context) {
+	var request apply.GroupBody
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	id, err := apply.CreateGroup(ctx, &request)
+	if err != nil {
+		ctx.JSON(409, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"id": id,
+	})
+}
+
+func UpdateGroup(ctx *gin.Context) {
+	var requestUri apply.GroupRequestUri
+	var request apply.GroupBody
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.UpdateGroup(ctx, requestUri.Id, &request)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func DeleteGroup(ctx *gin.Context) {
+	var requestUri apply.GroupRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.DeleteGroup(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "删除成功",
+	})
+}
@@ -0,0 +1,46 @@
+package notice
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/notice")
+	route.GET("", GetNoticeList)
+	route.PUT("/:id/read", MarkNoticeRead)
+}
+
+func GetNoticeList(ctx *gin.Context) {
+	var query apply.StaffNoticeQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.GetStaffNoticeList(ctx, &query))
+}
+
+func MarkNoticeRead(ctx *gin.Context) {
+	var requestUri apply.StaffNoticeRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.MarkStaffNoticeRead(ctx, requestUri.Id)
+	if err != nil {
+		switch v := err.(type) {
+		case *apply.StaffNoticeNotFoundError:
+			ctx.JSON(404, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/outbox")
+	route.GET("", GetOutboxEventList)
+	route.POST("/:id/retry", RetryOutboxEvent)
+}
+
+// GetOutboxEventList 查询待投递、已投递或投递失败的事件。
+func GetOutboxEventList(ctx *gin.Context) {
+	var query apply.OutboxEventQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.QueryOutboxEvents(ctx, &query))
+}
+
+// RetryOutboxEvent 重新投递一个投递失败的事件。
+func RetryOutboxEvent(ctx *gin.Context) {
+	var requestUri apply.OutboxEventRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.RetryOutboxEvent(ctx, requestUri.Id)
+	if err != nil {
+		switch v := err.(type) {
+		case *apply.OutboxEventNotFoundError:
+			ctx.JSON(404, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.OutboxEventNotDeadError:
+			ctx.JSON(409, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+	}
+	ctx.JSON(200, gin.H{
+		"message": "已重新加入投递队列",
+	})
+}
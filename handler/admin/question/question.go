@@ -0,0 +1,177 @@
+package question
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	questionRoute := group.Group("/question")
+	questionRoute.GET("", GetQuestionList)
+	questionRoute.POST("", CreateQuestion)
+	questionRoute.PUT("/:id", UpdateQuestion)
+	questionRoute.DELETE("/:id", DeleteQuestion)
+	sectionRoute := group.Group("/section")
+	sectionRoute.GET("", GetSectionList)
+	sectionRoute.POST("", CreateSection)
+	sectionRoute.PUT("/:id", UpdateSection)
+	sectionRoute.DELETE("/:id", DeleteSection)
+}
+
+func GetQuestionList(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{
+		"questions": apply.GetAllQuestionList(ctx),
+	})
+}
+
+func CreateQuestion(ctx *gin.Context) {
+	var request apply.QuestionBody
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	id, err := apply.CreateQuestion(ctx, &request)
+	if err != nil {
+		if v, ok := err.(*apply.DuplicateQuestionError); ok {
+			ctx.JSON(409, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+		ctx.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"id": id,
+	})
+}
+
+func UpdateQuestion(ctx *gin.Context) {
+	var requestUri apply.QuestionRequestUri
+	var request apply.QuestionBody
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.UpdateQuestion(ctx, requestUri.Id, &request)
+	if err != nil {
+		switch v := err.(type) {
+		case *apply.QuestionNotFoundError:
+			ctx.JSON(404, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.SectionNotFoundError, *apply.GroupNotFoundError, *apply.InvalidConditionError:
+			ctx.JSON(400, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func DeleteQuestion(ctx *gin.Context) {
+	var requestUri apply.QuestionRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.DeleteQuestion(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "删除成功",
+	})
+}
+
+func GetSectionList(ctx *gin.Context) {
+	ctx.JSON(200, apply.GetSectionList(ctx))
+}
+
+func CreateSection(ctx *gin.Context) {
+	var request apply.SectionBody
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	id, err := apply.CreateSection(ctx, &request)
+	if err != nil {
+		ctx.JSON(409, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"id": id,
+	})
+}
+
+func UpdateSection(ctx *gin.Context) {
+	var requestUri apply.SectionRequestUri
+	var request apply.SectionBody
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.UpdateSection(ctx, requestUri.Id, &request)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func DeleteSection(ctx *gin.Context) {
+	var requestUri apply.SectionRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.DeleteSection(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "删除成功",
+	})
+}
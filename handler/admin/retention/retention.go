@@ -0,0 +1,22 @@
+package retention
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/retention")
+	route.GET("", GetRetentionReport)
+	route.POST("/purge", PurgeRetention)
+}
+
+// GetRetentionReport 预演保留策略，返回将被清除的数据数量。
+func GetRetentionReport(ctx *gin.Context) {
+	ctx.JSON(200, apply.RunRetentionPolicy(ctx, true))
+}
+
+// PurgeRetention 立即按保留策略清除过期数据。
+func PurgeRetention(ctx *gin.Context) {
+	ctx.JSON(200, apply.RunRetentionPolicy(ctx, false))
+}
@@ -0,0 +1,22 @@
+package revision
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/revision")
+	route.GET("", GetRevisionList)
+}
+
+func GetRevisionList(ctx *gin.Context) {
+	var query apply.RevisionQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.QueryRevisionList(ctx, &query))
+}
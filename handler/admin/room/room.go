@@ -0,0 +1,156 @@
+package room
+
+import (
+	"elab-backend/model/apply"
+	"elab-backend/service/redis"
+	"elab-backend/util/ical"
+	"elab-backend/util/validate"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"mime"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/room")
+	route.GET("", GetRoomList)
+	route.POST("", CreateRoom)
+	route.PUT("/:id", UpdateRoom)
+	route.PUT("/:id/group", SetRoomGroup)
+	route.GET("/:id/calendar.ics", GetRoomCalendar)
+}
+
+func GetRoomList(ctx *gin.Context) {
+	ctx.JSON(200, gin.H{
+		"rooms": apply.GetAllRoomList(ctx),
+	})
+}
+
+// CreateRoom 创建房间，同一地点的可用房间时间不能重叠。
+func CreateRoom(ctx *gin.Context) {
+	var request apply.RoomBody
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	roomId, err := apply.CreateRoom(ctx, &request)
+	if err != nil {
+		respondRoomError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"id": roomId,
+	})
+}
+
+// UpdateRoom 更新房间，与选择房间共用同一把锁，避免容量校验与选择同时进行。
+func UpdateRoom(ctx *gin.Context) {
+	var requestUri apply.RoomRequestUri
+	var request apply.RoomBody
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		slog.Error("handler.admin.room.UpdateRoom: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.UpdateRoom(ctx, requestUri.Id, &request)
+	if err != nil {
+		respondRoomError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func respondRoomError(ctx *gin.Context, err error) {
+	switch v := err.(type) {
+	case *apply.RoomNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.RoomOverlapError:
+		ctx.JSON(409, gin.H{
+			"message": v.Error(),
+		})
+	case *validate.FieldError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+			"errors":  v.Fields,
+		})
+	default:
+		panic(err)
+	}
+}
+
+func SetRoomGroup(ctx *gin.Context) {
+	var requestUri apply.RoomRequestUri
+	var request apply.SetRoomGroupRequest
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.SetRoomGroupRestriction(ctx, requestUri.Id, request.Groups)
+	if err != nil {
+		switch v := err.(type) {
+		case *apply.RoomNotFoundError:
+			ctx.JSON(404, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.GroupNotFoundError:
+			ctx.JSON(400, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+// GetRoomCalendar 下载房间的面试日历，其中列出了已选择该房间的全部面试者。
+func GetRoomCalendar(ctx *gin.Context) {
+	var requestUri apply.RoomRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	calendar, err := apply.GetRoomCalendar(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "elab-room-" + requestUri.Id + ".ics"}))
+	ctx.Data(200, ical.ContentType, []byte(calendar.String()))
+}
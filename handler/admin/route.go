@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"elab-backend/handler/admin/attachment"
+	"elab-backend/handler/admin/audit"
+	"elab-backend/handler/admin/group"
+	"elab-backend/handler/admin/notice"
+	"elab-backend/handler/admin/outbox"
+	"elab-backend/handler/admin/question"
+	"elab-backend/handler/admin/retention"
+	"elab-backend/handler/admin/revision"
+	"elab-backend/handler/admin/room"
+	"elab-backend/handler/admin/ticket"
+	"elab-backend/handler/admin/webhook"
+	"elab-backend/middleware/auth"
+	"github.com/gin-gonic/gin"
+)
+
+func NewHandler(r *gin.RouterGroup) {
+	route := r.Group("/admin")
+	route.Use(auth.EnsureValidToken(), auth.EnsureAdmin())
+	attachment.ApplyRoute(route)
+	audit.ApplyRoute(route)
+	group.ApplyRoute(route)
+	notice.ApplyRoute(route)
+	outbox.ApplyRoute(route)
+	question.ApplyRoute(route)
+	retention.ApplyRoute(route)
+	revision.ApplyRoute(route)
+	room.ApplyRoute(route)
+	ticket.ApplyRoute(route)
+	webhook.ApplyRoute(route)
+}
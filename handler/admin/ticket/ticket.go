@@ -0,0 +1,87 @@
+package ticket
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/ticket")
+	route.GET("", GetTicketList)
+	route.GET("/duplicate", GetDuplicateReport)
+	route.PUT("/:openid/offer", MakeOffer)
+	route.DELETE("/:openid/offer", WithdrawOffer)
+}
+
+func GetTicketList(ctx *gin.Context) {
+	var query apply.TicketQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.QueryTicketList(ctx, &query))
+}
+
+func GetDuplicateReport(ctx *gin.Context) {
+	ctx.JSON(200, apply.GetDuplicateReport(ctx))
+}
+
+func MakeOffer(ctx *gin.Context) {
+	var requestUri apply.TicketRequestUri
+	var request apply.MakeOfferRequest
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.MakeOffer(ctx, requestUri.OpenId, request.Group)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "录取成功",
+	})
+}
+
+func WithdrawOffer(ctx *gin.Context) {
+	var requestUri apply.TicketRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.WithdrawOffer(ctx, requestUri.OpenId)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "撤销成功",
+	})
+}
+
+func respondOfferError(ctx *gin.Context, err error) {
+	switch v := err.(type) {
+	case *apply.TicketNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.GroupNotInPreferencesError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+		})
+	default:
+		panic(err)
+	}
+}
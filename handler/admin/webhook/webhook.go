@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/webhook")
+	route.GET("", GetWebhookList)
+	route.POST("", CreateWebhook)
+	route.PUT("/:id", UpdateWebhook)
+	route.DELETE("/:id", DeleteWebhook)
+	route.POST("/:id/secret", RotateWebhookSecret)
+	route.GET("/:id/delivery", GetWebhookDeliveryList)
+	route.POST("/:id/delivery/:delivery/redeliver", RedeliverWebhook)
+}
+
+func GetWebhookList(ctx *gin.Context) {
+	ctx.JSON(200, apply.GetWebhookList(ctx))
+}
+
+// CreateWebhook 创建端点，响应中的签名密钥只会返回这一次。
+func CreateWebhook(ctx *gin.Context) {
+	var request apply.WebhookBody
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.CreateWebhook(ctx, &request))
+}
+
+func UpdateWebhook(ctx *gin.Context) {
+	var requestUri apply.WebhookRequestUri
+	var request apply.WebhookBody
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.UpdateWebhook(ctx, requestUri.Id, &request)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func DeleteWebhook(ctx *gin.Context) {
+	var requestUri apply.WebhookRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.DeleteWebhook(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// RotateWebhookSecret 更换端点的签名密钥，旧密钥立即失效。
+func RotateWebhookSecret(ctx *gin.Context) {
+	var requestUri apply.WebhookRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	result, err := apply.RotateWebhookSecret(ctx, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, result)
+}
+
+func GetWebhookDeliveryList(ctx *gin.Context) {
+	var requestUri apply.WebhookRequestUri
+	var query apply.WebhookDeliveryQuery
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	result, err := apply.GetWebhookDeliveryList(ctx, requestUri.Id, &query)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, result)
+}
+
+// RedeliverWebhook 重新投递一条记录，投递会在后台进行。
+func RedeliverWebhook(ctx *gin.Context) {
+	var requestUri apply.WebhookDeliveryRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.RedeliverWebhook(ctx, requestUri.Id, requestUri.Delivery)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(202, gin.H{
+		"message": "已重新加入投递队列",
+	})
+}
@@ -0,0 +1,110 @@
+package attachment
+
+import (
+	"elab-backend/model/apply"
+	"elab-backend/service/redis"
+	"elab-backend/util/auth"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/attachment")
+	route.GET("", GetAttachmentList)
+	route.POST("", UploadAttachment)
+	route.DELETE("/:id", DeleteAttachment)
+}
+
+func GetAttachmentList(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	ctx.JSON(200, apply.GetAttachmentList(ctx, openid, ctx.Query("question_id")))
+}
+
+func UploadAttachment(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	maxSize := apply.GetAttachmentMaxSize()
+	// 为multipart的其他部分预留1MB
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize+1<<20)
+	var request apply.UploadAttachmentRequest
+	if err := ctx.ShouldBind(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误，缺少文件或文件过大",
+		})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "无法读取文件",
+		})
+		return
+	}
+	defer file.Close()
+	// 检查数量与保存附件之间不能有同一用户的其他上传，否则会超过数量限制
+	unlock, err := redis.GetLock(ctx, "attachment:"+openid)
+	if err != nil {
+		slog.Error("handler.apply.attachment.UploadAttachment: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	item, err := apply.CreateAttachment(ctx, openid, request.QuestionId, header.Filename, header.Size, file)
+	if err != nil {
+		switch v := err.(type) {
+		case *apply.AttachmentTooLargeError:
+			ctx.JSON(413, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.AttachmentTypeNotAllowedError:
+			ctx.JSON(415, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.AttachmentLimitExceededError, *apply.QuestionNotFoundError:
+			ctx.JSON(400, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+		ctx.JSON(400, gin.H{
+			"message": "上传失败",
+		})
+		return
+	}
+	ctx.JSON(200, item)
+}
+
+func DeleteAttachment(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var requestUri apply.AttachmentRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.DeleteAttachment(ctx, openid, requestUri.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "删除成功",
+	})
+}
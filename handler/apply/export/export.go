@@ -0,0 +1,38 @@
+package export
+
+import (
+	"elab-backend/model/apply"
+	"elab-backend/util/auth"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"mime"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/export")
+	route.GET("", ExportPersonalData)
+}
+
+// ExportPersonalData 下载用户的全部个人数据。
+// 没有附件时返回JSON文件，有附件时返回包含data.json与附件的ZIP压缩包。
+func ExportPersonalData(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	data := apply.GetPersonalData(ctx, openid)
+	fileName := "elab-data-" + data.ExportedAt.Format("20060102150405")
+	ctx.Header("Cache-Control", "private, no-store")
+	if len(data.Attachments) == 0 {
+		ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName + ".json"}))
+		ctx.IndentedJSON(200, data)
+		return
+	}
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName + ".zip"}))
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Status(200)
+	err := apply.WritePersonalDataZip(ctx, data, ctx.Writer)
+	if err != nil {
+		// 响应已经开始发送，无法再返回错误，客户端会收到不完整的压缩包
+		slog.Error("handler.apply.export.ExportPersonalData: 导出失败", "err", err, "openid", openid)
+		ctx.Abort()
+	}
+}
@@ -0,0 +1,15 @@
+package group
+
+import (
+	"elab-backend/model/apply"
+	"github.com/gin-gonic/gin"
+)
+
+func ApplyRoute(group *gin.RouterGroup) {
+	route := group.Group("/group")
+	route.GET("", GetGroupList)
+}
+
+func GetGroupList(ctx *gin.Context) {
+	ctx.JSON(200, apply.GetGroupList(ctx))
+}
@@ -4,33 +4,100 @@ import (
 	"elab-backend/model/apply"
 	"elab-backend/service/redis"
 	"elab-backend/util/auth"
+	"elab-backend/util/ical"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"io"
 	"log/slog"
+	"mime"
+	"time"
 )
 
 func ApplyRoute(group *gin.RouterGroup) {
 	route := group.Group("/room")
 	route.GET("", GetRoomList)
 	route.GET("/date", GetRoomDateList)
+	route.GET("/occupancy", GetRoomOccupancy)
 	route.POST("/selection", SetSelection)
 	route.DELETE("/selection", ClearSelection)
 	route.GET("/selection", GetSelection)
+	route.GET("/selection.ics", GetSelectionCalendar)
+	route.GET("/hold", GetSeatHold)
+	route.POST("/hold", PlaceSeatHold)
+	route.DELETE("/hold", ReleaseSeatHold)
+	route.POST("/hold/confirm", ConfirmSeatHold)
 }
 
 func GetRoomList(ctx *gin.Context) {
-	date := ctx.Query("date")
-	if date == "" {
+	var query apply.RoomQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
 		ctx.JSON(400, gin.H{
-			"message": "请求格式错误，缺少参数 date",
+			"message": "请求格式错误",
+		})
+		return
+	}
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	result, err := apply.GetRoomList(ctx, openid, &query)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"message": err.Error(),
 		})
 		return
 	}
-	ctx.JSON(200, apply.GetRoomList(ctx, date))
+	ctx.JSON(200, result)
 }
 
 func GetRoomDateList(ctx *gin.Context) {
-	ctx.JSON(200, apply.GetRoomDateList(ctx))
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	ctx.JSON(200, apply.GetRoomDateList(ctx, openid))
+}
+
+// GetRoomOccupancy 以Server-Sent Events推送某个日期房间占用情况的变化。
+// 连接建立后先推送每个房间当前的占用情况，之后每次变化推送一条occupancy事件，空闲时定期推送ping事件。
+func GetRoomOccupancy(ctx *gin.Context) {
+	date := ctx.Query("date")
+	if date == "" {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误，缺少参数 date",
+		})
+		return
+	}
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	subscription, err := apply.SubscribeRoomOccupancy(ctx, openid, date)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer subscription.Close()
+	ctx.Header("Cache-Control", "no-cache")
+	// 避免反向代理缓冲事件
+	ctx.Header("X-Accel-Buffering", "no")
+	for _, v := range subscription.Snapshot {
+		ctx.SSEvent("occupancy", v)
+	}
+	ctx.Writer.Flush()
+	heartbeat := time.NewTicker(apply.GetOccupancyHeartbeat())
+	defer heartbeat.Stop()
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case v, ok := <-subscription.Updates():
+			if !ok {
+				return false
+			}
+			ctx.SSEvent("occupancy", v)
+			return true
+		case <-heartbeat.C:
+			ctx.SSEvent("ping", "")
+			return true
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
 }
 
 func SetSelection(ctx *gin.Context) {
@@ -65,6 +132,16 @@ func SetSelection(ctx *gin.Context) {
 				"message": v.Error(),
 			})
 			return
+		case *apply.RoomNotFoundError:
+			ctx.JSON(404, gin.H{
+				"message": v.Error(),
+			})
+			return
+		case *apply.RoomGroupMismatchError:
+			ctx.JSON(400, gin.H{
+				"message": v.Error(),
+			})
+			return
 		}
 	}
 	ctx.JSON(200, gin.H{
@@ -123,3 +200,130 @@ func GetSelection(ctx *gin.Context) {
 		"id": selection.RoomId,
 	})
 }
+
+// GetSelectionCalendar 下载用户所选面试场次的日历文件，可以导入手机日历。
+func GetSelectionCalendar(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	calendar, err := apply.GetSelectionCalendar(ctx, openid)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "elab-interview.ics"}))
+	ctx.Data(200, ical.ContentType, []byte(calendar.String()))
+}
+
+func GetSeatHold(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	hold, err := apply.GetSeatHold(ctx, openid)
+	if err != nil {
+		respondSeatHoldError(ctx, err)
+		return
+	}
+	ctx.JSON(200, hold)
+}
+
+// PlaceSeatHold 临时保留房间中的一个座位，用户需要在过期前确认，否则座位会被归还。
+func PlaceSeatHold(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var request apply.SetRoomSelectionRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil || request.Id == "" {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		slog.Error("handler.apply.room.PlaceSeatHold: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	hold, err := apply.PlaceSeatHold(ctx, openid, request.Id)
+	if err != nil {
+		respondSeatHoldError(ctx, err)
+		return
+	}
+	ctx.JSON(200, hold)
+}
+
+func ReleaseSeatHold(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		slog.Error("handler.apply.room.ReleaseSeatHold: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.ReleaseSeatHold(ctx, openid)
+	if err != nil {
+		respondSeatHoldError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "取消成功",
+	})
+}
+
+// ConfirmSeatHold 将保留的座位确认为房间选择。
+func ConfirmSeatHold(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		slog.Error("handler.apply.room.ConfirmSeatHold: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.ConfirmSeatHold(ctx, openid)
+	if err != nil {
+		respondSeatHoldError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "更新成功",
+	})
+}
+
+func respondSeatHoldError(ctx *gin.Context, err error) {
+	switch v := err.(type) {
+	case *apply.SeatHoldNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.RoomNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.RoomFullError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.DuplicateSelectionError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.RoomGroupMismatchError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+		})
+	default:
+		panic(err)
+	}
+}
@@ -1,6 +1,9 @@
 package apply
 
 import (
+	"elab-backend/handler/apply/attachment"
+	"elab-backend/handler/apply/export"
+	"elab-backend/handler/apply/group"
 	"elab-backend/handler/apply/room"
 	"elab-backend/handler/apply/status"
 	"elab-backend/handler/apply/textform"
@@ -10,11 +13,14 @@ import (
 )
 
 func NewHandler(r *gin.RouterGroup) {
-	group := r.Group("/apply")
-	group.Use(auth.EnsureValidToken())
-	group.GET("/config", GetConfig)
-	room.ApplyRoute(group)
-	status.ApplyRoute(group)
-	textform.ApplyRoute(group)
-	ticket.ApplyRoute(group)
+	route := r.Group("/apply")
+	route.Use(auth.EnsureValidToken())
+	route.GET("/config", GetConfig)
+	attachment.ApplyRoute(route)
+	export.ApplyRoute(route)
+	group.ApplyRoute(route)
+	room.ApplyRoute(route)
+	status.ApplyRoute(route)
+	textform.ApplyRoute(route)
+	ticket.ApplyRoute(route)
 }
@@ -20,6 +20,7 @@ func GetStatus(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(500, gin.H{
 			"message": "服务器错误",
 		})
+		return
 	}
 	defer unlock()
 	ctx.JSON(200, apply.GetStatus(ctx, openid))
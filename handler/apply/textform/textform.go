@@ -12,6 +12,8 @@ func ApplyRoute(group *gin.RouterGroup) {
 	textFormRoute.Use(LockMiddleware())
 	textFormRoute.GET("", GetTextForm)
 	textFormRoute.PATCH("/:id", UpdateTextForm)
+	textFormRoute.GET("/:id/revision", GetTextFormRevisionList)
+	textFormRoute.POST("/:id/revision/:revision/restore", RestoreTextFormRevision)
 	questionRoute := group.Group("/question")
 	questionRoute.Use(LockMiddleware())
 	questionRoute.GET("", GetQuestionList)
@@ -27,6 +29,7 @@ func LockMiddleware() gin.HandlerFunc {
 			ctx.AbortWithStatusJSON(500, gin.H{
 				"message": "服务器错误",
 			})
+			return
 		}
 		defer unlock()
 		ctx.Next()
@@ -55,7 +58,14 @@ func GetQuestion(ctx *gin.Context) {
 		})
 		return
 	}
-	ctx.JSON(200, apply.GetQuestion(ctx, openid, request.Id))
+	question, err := apply.GetQuestion(ctx, openid, request.Id)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, question)
 }
 
 func UpdateTextForm(ctx *gin.Context) {
@@ -76,8 +86,49 @@ func UpdateTextForm(ctx *gin.Context) {
 		return
 	}
 	request.Id = requestUri.Id
-	apply.UpdateTextForm(ctx, openid, &request)
+	err := apply.UpdateTextForm(ctx, openid, &request)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 	ctx.JSON(200, gin.H{
 		"message": "更新成功",
 	})
 }
+
+func GetTextFormRevisionList(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var requestUri apply.UpdateTextFormRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	ctx.JSON(200, apply.GetRevisionList(ctx, openid, apply.RevisionTypeTextForm, requestUri.Id))
+}
+
+func RestoreTextFormRevision(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var requestUri apply.RestoreRevisionRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil || requestUri.Id == "" {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	err := apply.RestoreTextFormRevision(ctx, openid, requestUri.Id, requestUri.Revision)
+	if err != nil {
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "恢复成功",
+	})
+}
@@ -2,14 +2,22 @@ package ticket
 
 import (
 	"elab-backend/model/apply"
+	"elab-backend/service/redis"
 	"elab-backend/util/auth"
+	"elab-backend/util/validate"
+	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"log/slog"
+	"strings"
 )
 
 func ApplyRoute(group *gin.RouterGroup) {
 	route := group.Group("/ticket")
 	route.GET("", GetTicket)
 	route.PATCH("", UpdateTicket)
+	route.GET("/revision", GetTicketRevisionList)
+	route.POST("/revision/:revision/restore", RestoreTicketRevision)
+	route.POST("/withdraw", WithdrawTicket)
 }
 
 func GetTicket(ctx *gin.Context) {
@@ -22,14 +30,131 @@ func UpdateTicket(ctx *gin.Context) {
 	token := auth.GetToken(ctx)
 	openid := token.RegisteredClaims.Subject
 	var request apply.TicketBody
-	if err := ctx.ShouldBindJSON(&request); err != nil {
+	// 字段规则在模型层去除首尾空白后统一校验，这里只解析JSON
+	if err := json.NewDecoder(ctx.Request.Body).Decode(&request); err != nil {
 		ctx.JSON(400, gin.H{
 			"message": "请求格式错误",
 		})
 		return
 	}
-	apply.UpdateTicket(ctx, openid, &request)
+	unlock, err := redis.GetLock(ctx, apply.GetStudentIdLockKey(request.StudentId))
+	if err != nil {
+		slog.Error("handler.apply.ticket.UpdateTicket: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.UpdateTicket(ctx, openid, &request)
+	if err != nil {
+		respondTicketError(ctx, err)
+		return
+	}
 	ctx.JSON(200, gin.H{
 		"message": "更新成功",
 	})
 }
+
+func GetTicketRevisionList(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	ctx.JSON(200, apply.GetRevisionList(ctx, openid, apply.RevisionTypeTicket, ""))
+}
+
+func RestoreTicketRevision(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var requestUri apply.RestoreRevisionRequestUri
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	body, err := apply.GetTicketRevision(ctx, openid, requestUri.Revision)
+	if err != nil {
+		respondTicketError(ctx, err)
+		return
+	}
+	unlock, err := redis.GetLock(ctx, apply.GetStudentIdLockKey(body.StudentId))
+	if err != nil {
+		slog.Error("handler.apply.ticket.RestoreTicketRevision: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.RestoreTicketRevision(ctx, openid, requestUri.Revision)
+	if err != nil {
+		respondTicketError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "恢复成功",
+	})
+}
+
+func WithdrawTicket(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	var request apply.WithdrawRequest
+	// 撤回原因是可选的，允许不带请求体
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(400, gin.H{
+				"message": "请求格式错误",
+			})
+			return
+		}
+	}
+	// 撤回会释放房间选择，与选择房间共用同一把锁
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		slog.Error("handler.apply.ticket.WithdrawTicket: 获取锁失败", "err", err)
+		ctx.JSON(400, gin.H{
+			"message": "请求失败",
+		})
+		return
+	}
+	defer unlock()
+	err = apply.WithdrawTicket(ctx, openid, strings.TrimSpace(request.Reason))
+	if err != nil {
+		respondTicketError(ctx, err)
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"message": "撤回成功",
+	})
+}
+
+func respondTicketError(ctx *gin.Context, err error) {
+	switch v := err.(type) {
+	case *validate.FieldError:
+		ctx.JSON(400, gin.H{
+			"message": v.Error(),
+			"errors":  v.Fields,
+		})
+	case *apply.DuplicateApplicationError:
+		ctx.JSON(409, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.RevisionNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.TicketNotFoundError:
+		ctx.JSON(404, gin.H{
+			"message": v.Error(),
+		})
+	case *apply.ApplicationClosedError:
+		ctx.JSON(403, gin.H{
+			"message": v.Error(),
+		})
+	default:
+		ctx.JSON(400, gin.H{
+			"message": err.Error(),
+		})
+	}
+}
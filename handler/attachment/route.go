@@ -0,0 +1,52 @@
+package attachment
+
+import (
+	"elab-backend/model/apply"
+	"elab-backend/util/sign"
+	"github.com/gin-gonic/gin"
+	"mime"
+)
+
+// NewHandler 注册附件下载路由。
+// 下载链接由签名保护，不需要登录，便于在浏览器中直接打开。
+func NewHandler(r *gin.RouterGroup) {
+	route := r.Group("/attachment")
+	route.GET("/:id", DownloadAttachment)
+}
+
+func DownloadAttachment(ctx *gin.Context) {
+	var requestUri apply.AttachmentRequestUri
+	var request apply.DownloadAttachmentRequest
+	if err := ctx.ShouldBindUri(&requestUri); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	if err := ctx.ShouldBindQuery(&request); err != nil {
+		ctx.JSON(400, gin.H{
+			"message": "请求格式错误",
+		})
+		return
+	}
+	attachment, reader, err := apply.OpenAttachment(ctx, requestUri.Id, &request)
+	if err != nil {
+		switch v := err.(type) {
+		case *sign.InvalidSignatureError, *sign.SignatureExpiredError:
+			ctx.JSON(403, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
+		ctx.JSON(404, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	defer reader.Close()
+	ctx.DataFromReader(200, attachment.Size, attachment.ContentType, reader, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, no-store",
+	})
+}
@@ -1,21 +1,38 @@
 package auth
 
 import (
+	"elab-backend/model/apply"
 	"elab-backend/util/auth"
 	"github.com/gin-gonic/gin"
 )
 
+// DeleteAccount 申请删除账号，宽限期结束后由后台任务删除数据与Auth0用户。
 func DeleteAccount(ctx *gin.Context) {
 	token := auth.GetToken(ctx)
 	openid := token.RegisteredClaims.Subject
-	err := auth.DeleteAccount(ctx, openid)
+	ctx.JSON(202, apply.RequestAccountDeletion(ctx, openid))
+}
+
+func GetAccountDeletion(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	ctx.JSON(200, apply.GetAccountDeletion(ctx, openid))
+}
+
+func CancelAccountDeletion(ctx *gin.Context) {
+	token := auth.GetToken(ctx)
+	openid := token.RegisteredClaims.Subject
+	err := apply.CancelAccountDeletion(ctx, openid)
 	if err != nil {
-		ctx.JSON(400, gin.H{
-			"message": err.Error(),
-		})
-		return
+		switch v := err.(type) {
+		case *apply.DeletionNotFoundError:
+			ctx.JSON(400, gin.H{
+				"message": v.Error(),
+			})
+			return
+		}
 	}
 	ctx.JSON(200, gin.H{
-		"message": "删除成功",
+		"message": "已撤销删除",
 	})
 }
@@ -1,8 +1,14 @@
 package auth
 
-import "github.com/gin-gonic/gin"
+import (
+	"elab-backend/middleware/auth"
+	"github.com/gin-gonic/gin"
+)
 
 func NewHandler(r *gin.RouterGroup) {
 	route := r.Group("/auth")
+	route.Use(auth.EnsureValidToken())
 	route.DELETE("", DeleteAccount)
+	route.GET("/deletion", GetAccountDeletion)
+	route.DELETE("/deletion", CancelAccountDeletion)
 }
@@ -1,17 +1,25 @@
 package handler
 
 import (
+	"elab-backend/handler/admin"
 	"elab-backend/handler/apply"
+	"elab-backend/handler/attachment"
 	"elab-backend/handler/auth"
+	"elab-backend/middleware/request"
+	"elab-backend/util/validate"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 )
 
 func Init() *gin.Engine {
 	slog.Info("handler.Init: 正在初始化路由")
+	validate.Init()
 	r := gin.Default()
+	r.Use(request.EnsureRequestId(), request.DetectLanguage())
 	endpoint := r.Group("/v1")
+	admin.NewHandler(endpoint)
 	apply.NewHandler(endpoint)
+	attachment.NewHandler(endpoint)
 	auth.NewHandler(endpoint)
 	endpoint.GET("", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -0,0 +1,53 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/service/redis"
+	"elab-backend/util/auth"
+	"elab-backend/util/config"
+	"log/slog"
+	"time"
+)
+
+// newDeletionJob 创建账号删除任务，执行间隔由ACCOUNT_DELETION_INTERVAL指定，默认为10分钟。
+func newDeletionJob() Job {
+	return Job{
+		Name:     "account_deletion",
+		Interval: config.GetDuration("ACCOUNT_DELETION_INTERVAL", 10*time.Minute),
+		Run:      runAccountDeletion,
+	}
+}
+
+// runAccountDeletion 执行到期的账号删除任务。
+// 先在事务中清除数据库中的数据，再删除Auth0中的用户，任一步失败都会稍后重试。
+func runAccountDeletion(ctx context.Context) error {
+	deletions := apply.GetDueAccountDeletions(ctx)
+	slog.Debug("job.runAccountDeletion: 到期的删除任务", "count", len(deletions))
+	for i := range deletions {
+		deletion := &deletions[i]
+		err := purgeAccount(ctx, deletion)
+		if err != nil {
+			apply.FailAccountDeletion(ctx, deletion, err)
+			continue
+		}
+		apply.CompleteAccountDeletion(ctx, deletion)
+	}
+	return nil
+}
+
+func purgeAccount(ctx context.Context, deletion *apply.AccountDeletion) error {
+	if deletion.DataPurgedAt == nil {
+		// 清除数据会释放房间选择，与选择房间共用同一把锁
+		unlock, err := redis.GetLock(ctx, "room_selection")
+		if err != nil {
+			return err
+		}
+		err = apply.PurgeAccountData(ctx, deletion)
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return auth.DeleteIdentity(ctx, deletion.OpenId)
+}
@@ -0,0 +1,24 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/util/config"
+	"time"
+)
+
+// newEncryptionJob 创建重新加密任务，执行间隔由ENCRYPTION_ROTATION_INTERVAL指定，默认为1小时。
+// 任务在启动时立即执行一次，更换密钥后重启即可开始重新加密，多个实例只会有一个执行。
+func newEncryptionJob() Job {
+	return Job{
+		Name:     "encryption",
+		Interval: config.GetDuration("ENCRYPTION_ROTATION_INTERVAL", time.Hour),
+		Run:      runEncryptionRotation,
+	}
+}
+
+// runEncryptionRotation 用当前版本的密钥重新加密旧的数据。
+func runEncryptionRotation(ctx context.Context) error {
+	apply.RotateEncryptedFields(ctx)
+	return nil
+}
@@ -0,0 +1,35 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/service/redis"
+	"elab-backend/util/config"
+	"log/slog"
+	"time"
+)
+
+// newSeatHoldJob 创建座位保留清理任务，执行间隔由SEAT_HOLD_SWEEP_INTERVAL指定，默认为10秒。
+// 过期的保留在清除前不会被确认，但仍计入房间的Held，间隔决定了座位最多晚多久归还。
+func newSeatHoldJob() Job {
+	return Job{
+		Name:     "seat_hold",
+		Interval: config.GetDuration("SEAT_HOLD_SWEEP_INTERVAL", 10*time.Second),
+		Run:      runSeatHoldSweep,
+	}
+}
+
+// runSeatHoldSweep 清除过期的座位保留并归还座位。
+func runSeatHoldSweep(ctx context.Context) error {
+	// 清除保留会修改房间的Held，与选择房间共用同一把锁
+	unlock, err := redis.GetLock(ctx, "room_selection")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	count := apply.ReleaseExpiredSeatHolds(ctx)
+	if count > 0 {
+		slog.Debug("job.runSeatHoldSweep: 已清除过期的座位保留", "count", count)
+	}
+	return nil
+}
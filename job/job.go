@@ -0,0 +1,65 @@
+package job
+
+import (
+	"context"
+	"elab-backend/service/redis"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Job 是定期执行的后台任务。
+type Job struct {
+	// Name 是任务的名称，同时用作分布式锁的键。
+	Name string
+	// Interval 是两次执行之间的间隔。
+	Interval time.Duration
+	// Run 是任务的内容，返回错误时只记录日志，下一次仍会执行。
+	Run func(ctx context.Context) error
+}
+
+// Init 启动全部后台任务。
+// 每次执行前会尝试获取Redis锁，多个实例同时运行时同一任务只会有一个实例执行。
+func Init() {
+	slog.Info("job.Init: 正在启动后台任务")
+	for _, job := range []Job{
+		newDeletionJob(),
+		newRetentionJob(),
+		newOutboxJob(),
+		newWebhookJob(),
+		newReminderJob(),
+		newSeatHoldJob(),
+		newEncryptionJob(),
+	} {
+		go job.loop()
+	}
+}
+
+func (job Job) loop() {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		job.runOnce()
+		<-ticker.C
+	}
+}
+
+func (job Job) runOnce() {
+	ctx := context.Background()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("job: 任务异常", "job", job.Name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+		}
+	}()
+	unlock, err := redis.TryLock(ctx, "job:"+job.Name, job.Interval)
+	if err != nil || unlock == nil {
+		return
+	}
+	defer unlock()
+	slog.Debug("job: 正在执行任务", "job", job.Name)
+	err = job.Run(ctx)
+	if err != nil {
+		slog.Error("job: 任务执行失败", "job", job.Name, "error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/util/config"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// outboxConsumers 是事件的消费方，事件需要被全部消费方成功处理才算投递成功。
+// 任一消费方失败时整个事件会被重试，因此消费方需要能处理重复的事件。
+var outboxConsumers = []func(ctx context.Context, event *apply.OutboxEvent) error{
+	apply.EnqueueWebhookDeliveries,
+	apply.DeliverNotification,
+}
+
+// newOutboxJob 创建事件投递任务，执行间隔由OUTBOX_INTERVAL指定，默认为5秒。
+// 每次最多投递OUTBOX_BATCH_SIZE个事件，默认为100个。
+func newOutboxJob() Job {
+	return Job{
+		Name:     "outbox",
+		Interval: config.GetDuration("OUTBOX_INTERVAL", 5*time.Second),
+		Run:      runOutboxDispatch,
+	}
+}
+
+// runOutboxDispatch 按产生的顺序投递到期的事件，失败的事件按指数退避重试。
+// 每个事件投递前先认领，多个实例同时运行时同一事件只会被投递一次。
+func runOutboxDispatch(ctx context.Context) error {
+	events := apply.GetDueOutboxEvents(ctx, int(config.GetInt("OUTBOX_BATCH_SIZE", 100)))
+	if len(events) > 0 {
+		slog.Debug("job.runOutboxDispatch: 到期的事件", "count", len(events))
+	}
+	for i := range events {
+		event := &events[i]
+		if !apply.ClaimOutboxEvent(ctx, event) {
+			continue
+		}
+		err := dispatchOutboxEvent(ctx, event)
+		if err != nil {
+			apply.FailOutboxEvent(ctx, event, err)
+			continue
+		}
+		apply.CompleteOutboxEvent(ctx, event)
+	}
+	return nil
+}
+
+// dispatchOutboxEvent 将事件交给全部消费方处理，消费方的异常也视为投递失败。
+func dispatchOutboxEvent(ctx context.Context, event *apply.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("消费方异常：%v", r)
+		}
+	}()
+	for _, consumer := range outboxConsumers {
+		err := consumer(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
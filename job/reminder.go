@@ -0,0 +1,27 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/util/config"
+	"log/slog"
+	"time"
+)
+
+// newReminderJob 创建面试提醒任务，执行间隔由REMINDER_INTERVAL指定，默认为1分钟。
+// 提醒的时间点见apply.GetReminderOffsets。
+func newReminderJob() Job {
+	return Job{
+		Name:     "interview_reminder",
+		Interval: config.GetDuration("REMINDER_INTERVAL", time.Minute),
+		Run:      runInterviewReminder,
+	}
+}
+
+func runInterviewReminder(ctx context.Context) error {
+	count := apply.ScheduleInterviewReminders(ctx)
+	if count > 0 {
+		slog.Info("job.runInterviewReminder: 已安排面试提醒", "count", count)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/util/config"
+	"log/slog"
+	"time"
+)
+
+// newRetentionJob 创建数据保留任务，执行间隔由RETENTION_INTERVAL指定，默认为24小时。
+// RETENTION_DRY_RUN为true时只记录将被清除的数据，不做修改。
+func newRetentionJob() Job {
+	return Job{
+		Name:     "retention",
+		Interval: config.GetDuration("RETENTION_INTERVAL", 24*time.Hour),
+		Run:      runRetention,
+	}
+}
+
+func runRetention(ctx context.Context) error {
+	report := apply.RunRetentionPolicy(ctx, config.GetBool("RETENTION_DRY_RUN", false))
+	for _, item := range report.Items {
+		if item.Enabled {
+			slog.Info("job.runRetention: 保留策略执行结果", "dryRun", report.DryRun, "dataType", item.DataType,
+				"action", item.Action, "count", item.Count)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package job
+
+import (
+	"context"
+	"elab-backend/model/apply"
+	"elab-backend/util/config"
+	"log/slog"
+	"time"
+)
+
+// newWebhookJob 创建Webhook投递任务，执行间隔由WEBHOOK_INTERVAL指定，默认为5秒。
+// 每次最多投递WEBHOOK_BATCH_SIZE条记录，默认为50条。
+func newWebhookJob() Job {
+	return Job{
+		Name:     "webhook",
+		Interval: config.GetDuration("WEBHOOK_INTERVAL", 5*time.Second),
+		Run:      runWebhookDelivery,
+	}
+}
+
+// runWebhookDelivery 发送到期的Webhook投递，失败的投递按指数退避重试。
+func runWebhookDelivery(ctx context.Context) error {
+	deliveries := apply.GetDueWebhookDeliveries(ctx, int(config.GetInt("WEBHOOK_BATCH_SIZE", 50)))
+	if len(deliveries) > 0 {
+		slog.Debug("job.runWebhookDelivery: 到期的投递", "count", len(deliveries))
+	}
+	for i := range deliveries {
+		apply.SendWebhookDelivery(ctx, &deliveries[i])
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package auth
 
 import (
 	"elab-backend/util/auth"
+	"elab-backend/util/request"
 	jwtmiddleware "github.com/auth0/go-jwt-middleware/v2"
+	"github.com/auth0/go-jwt-middleware/v2/validator"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
@@ -37,6 +39,9 @@ func EnsureValidToken() gin.HandlerFunc {
 		var handler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 			encounteredError = false
 			c.Request = r
+			if claims, ok := r.Context().Value(jwtmiddleware.ContextKey{}).(*validator.ValidatedClaims); ok {
+				c.Set(request.ActorKey, claims.RegisteredClaims.Subject)
+			}
 			c.Next()
 		}
 
@@ -49,3 +54,18 @@ func EnsureValidToken() gin.HandlerFunc {
 		}
 	}
 }
+
+// EnsureAdmin 用于检查用户是否为管理员，需要在EnsureValidToken之后使用。
+// 管理员需要在Token中拥有AUTH0_ADMIN_SCOPE指定的scope或权限。
+func EnsureAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IsAdmin(c) {
+			slog.Debug("middleware.auth.EnsureAdmin: 用户不是管理员")
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "权限不足。",
+			})
+			return
+		}
+		c.Next()
+	}
+}
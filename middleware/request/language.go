@@ -0,0 +1,27 @@
+package request
+
+import (
+	"elab-backend/util/request"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// DetectLanguage 根据Accept-Language判断客户端的首选语言，目前只区分中文与英文。
+func DetectLanguage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		language := request.LanguageChinese
+		for _, item := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+			tag, _, _ := strings.Cut(strings.TrimSpace(item), ";")
+			tag = strings.ToLower(tag)
+			if strings.HasPrefix(tag, "zh") {
+				break
+			}
+			if strings.HasPrefix(tag, "en") {
+				language = request.LanguageEnglish
+				break
+			}
+		}
+		c.Set(request.LanguageKey, language)
+		c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package request
+
+import (
+	"elab-backend/util/request"
+	"github.com/gin-gonic/gin"
+)
+
+// EnsureRequestId 为每个请求分配请求ID，并写入响应头。
+// 若客户端已在请求头中提供请求ID，则沿用客户端的值。
+func EnsureRequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(request.IdHeader)
+		if id == "" || len(id) > 64 {
+			id = request.NewRequestId()
+		}
+		c.Set(request.IdKey, id)
+		c.Header(request.IdHeader, id)
+		c.Next()
+	}
+}
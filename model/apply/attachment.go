@@ -0,0 +1,338 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"elab-backend/util/sign"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+// Attachment 是用户上传的附件，如简历、作品集等。
+type Attachment struct {
+	gorm.Model
+	// AttachmentId 是附件的唯一标识符。
+	AttachmentId string `gorm:"type:varchar(36);index"`
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// QuestionId 是附件关联的问题ID，为空时表示附在申请上。
+	QuestionId string `gorm:"type:varchar(36)"`
+	// FileName 是上传时的文件名。
+	FileName string `gorm:"type:varchar(255)"`
+	// ContentType 是根据文件内容检测出的MIME类型。
+	ContentType string `gorm:"type:varchar(127)"`
+	// Size 是文件大小，单位为字节。
+	Size int64 `gorm:"type:bigint"`
+	// StorageKey 是文件在存储后端中的键。
+	StorageKey string `gorm:"type:varchar(255)"`
+}
+
+type UploadAttachmentRequest struct {
+	// QuestionId 是附件关联的问题ID，可以为空。
+	QuestionId string `form:"question_id" binding:"omitempty,max=36"`
+}
+
+type AttachmentRequestUri struct {
+	// Id 是附件的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+}
+
+// DownloadAttachmentRequest 是下载附件时携带的签名参数。
+type DownloadAttachmentRequest struct {
+	// Expires 是链接的过期时间，为Unix时间戳。
+	Expires int64 `form:"expires" binding:"required"`
+	// Signature 是链接的签名。
+	Signature string `form:"signature" binding:"required"`
+}
+
+// AttachmentQuery 是管理员查询附件的条件。
+type AttachmentQuery struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `form:"openid" binding:"required"`
+	// QuestionId 是附件关联的问题ID。
+	QuestionId string `form:"question_id"`
+}
+
+// AttachmentListItem 是附件列表项。
+type AttachmentListItem struct {
+	// Id 是附件的唯一标识符。
+	Id string `json:"id"`
+	// QuestionId 是附件关联的问题ID。
+	QuestionId string `json:"question_id"`
+	// FileName 是上传时的文件名。
+	FileName string `json:"file_name"`
+	// ContentType 是文件的MIME类型。
+	ContentType string `json:"content_type"`
+	// Size 是文件大小，单位为字节。
+	Size int64 `json:"size"`
+	// CreatedAt 是上传时间。
+	CreatedAt time.Time `json:"created_at"`
+	// Url 是带签名的下载链接。
+	Url string `json:"url"`
+	// ExpiresAt 是下载链接的过期时间。
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetAttachmentListResponse 是获取附件列表的响应。
+type GetAttachmentListResponse struct {
+	Attachments []AttachmentListItem `json:"attachments"`
+}
+
+type AttachmentNotFoundError struct{}
+
+func (e *AttachmentNotFoundError) Error() string {
+	return "附件不存在"
+}
+
+type AttachmentTooLargeError struct {
+	// Limit 是允许的最大文件大小，单位为字节。
+	Limit int64
+}
+
+func (e *AttachmentTooLargeError) Error() string {
+	return fmt.Sprintf("附件过大，最大允许%dMB", e.Limit>>20)
+}
+
+type AttachmentTypeNotAllowedError struct {
+	// ContentType 是检测到的MIME类型。
+	ContentType string
+}
+
+func (e *AttachmentTypeNotAllowedError) Error() string {
+	return "不支持的附件类型：" + e.ContentType
+}
+
+type AttachmentLimitExceededError struct{}
+
+func (e *AttachmentLimitExceededError) Error() string {
+	return "附件数量已达上限"
+}
+
+// GetAttachmentMaxSize 获取单个附件的大小上限，由ATTACHMENT_MAX_SIZE指定，默认为10MB。
+func GetAttachmentMaxSize() int64 {
+	return config.GetInt("ATTACHMENT_MAX_SIZE", 10<<20)
+}
+
+// getAttachmentAllowedTypes 获取允许的MIME类型，由ATTACHMENT_ALLOWED_TYPES指定。
+func getAttachmentAllowedTypes() []string {
+	return config.GetList("ATTACHMENT_ALLOWED_TYPES", []string{
+		"application/pdf",
+		"image/png",
+		"image/jpeg",
+		"application/zip",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	})
+}
+
+// getAttachmentMaxCount 获取每个用户的附件数量上限，由ATTACHMENT_MAX_COUNT指定，默认为5。
+func getAttachmentMaxCount() int64 {
+	return config.GetInt("ATTACHMENT_MAX_COUNT", 5)
+}
+
+// getAttachmentUrlTTL 获取下载链接的有效期，由ATTACHMENT_URL_TTL指定，默认为15分钟。
+func getAttachmentUrlTTL() time.Duration {
+	return config.GetDuration("ATTACHMENT_URL_TTL", 15*time.Minute)
+}
+
+// CreateAttachment 保存用户上传的附件。
+// 文件类型根据内容检测，而不是相信客户端提供的类型。
+// 调用方需要持有该用户附件的锁（“attachment:”+openid），否则并发上传可能超过ATTACHMENT_MAX_COUNT。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// questionId 是附件关联的问题ID，可以为空。
+// fileName 是上传时的文件名。
+// size 是文件大小。
+// reader 是文件内容。
+func CreateAttachment(ctx context.Context, openid string, questionId string, fileName string, size int64, reader io.ReadSeeker) (*AttachmentListItem, error) {
+	slog.Debug("model.CreateAttachment: 正在保存附件", "openid", openid, "questionId", questionId, "size", size)
+	maxSize := GetAttachmentMaxSize()
+	if size > maxSize {
+		return nil, &AttachmentTooLargeError{Limit: maxSize}
+	}
+	if questionId != "" && !CheckIsQuestionExists(ctx, questionId) {
+		return nil, &QuestionNotFoundError{}
+	}
+	srv := service.GetService()
+	var count int64
+	err := srv.DB.WithContext(ctx).Model(&Attachment{}).Where(&Attachment{OpenId: openid}).Count(&count).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	if count >= getAttachmentMaxCount() {
+		return nil, &AttachmentLimitExceededError{}
+	}
+	detected, err := mimetype.DetectReader(reader)
+	if err != nil {
+		slog.Error("model.CreateAttachment: 无法检测文件类型", "error", err)
+		return nil, err
+	}
+	contentType := detected.String()
+	if !mimetype.EqualsAny(contentType, getAttachmentAllowedTypes()...) {
+		slog.Debug("model.CreateAttachment: 不支持的文件类型", "contentType", contentType)
+		return nil, &AttachmentTypeNotAllowedError{ContentType: contentType}
+	}
+	_, err = reader.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	attachment := Attachment{
+		AttachmentId: uuid.NewString(),
+		OpenId:       openid,
+		QuestionId:   questionId,
+		FileName:     filepath.Base(fileName),
+		ContentType:  contentType,
+		Size:         size,
+	}
+	attachment.StorageKey = "attachment/" + attachment.AttachmentId
+	err = srv.Storage.Put(ctx, attachment.StorageKey, io.LimitReader(reader, maxSize), size, attachment.ContentType)
+	if err != nil {
+		slog.Error("调用存储服务失败。", "error", err)
+		panic(err)
+	}
+	err = srv.DB.WithContext(ctx).Create(&attachment).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		_ = srv.Storage.Delete(ctx, attachment.StorageKey)
+		panic(err)
+	}
+	item := newAttachmentListItem(&attachment)
+	return &item, nil
+}
+
+// GetAttachmentList 获取用户的附件列表，附带签名的下载链接。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// questionId 是附件关联的问题ID，为空时返回全部附件。
+func GetAttachmentList(ctx context.Context, openid string, questionId string) *GetAttachmentListResponse {
+	slog.Debug("model.GetAttachmentList: 正在获取附件列表", "openid", openid, "questionId", questionId)
+	attachments := findAttachmentList(ctx, openid, questionId)
+	result := GetAttachmentListResponse{Attachments: make([]AttachmentListItem, 0, len(attachments))}
+	for _, v := range attachments {
+		result.Attachments = append(result.Attachments, newAttachmentListItem(&v))
+	}
+	return &result
+}
+
+func findAttachmentList(ctx context.Context, openid string, questionId string) []Attachment {
+	srv := service.GetService()
+	var attachments []Attachment
+	err := srv.DB.WithContext(ctx).Model(&Attachment{}).Where(&Attachment{
+		OpenId:     openid,
+		QuestionId: questionId,
+	}).Order("id").Find(&attachments).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return attachments
+}
+
+// newAttachmentListItem 生成附件列表项，并为其签发下载链接。
+func newAttachmentListItem(attachment *Attachment) AttachmentListItem {
+	expiresAt := time.Now().Add(getAttachmentUrlTTL())
+	query := url.Values{}
+	query.Set("expires", fmt.Sprint(expiresAt.Unix()))
+	query.Set("signature", sign.Sign(attachment.AttachmentId, expiresAt))
+	return AttachmentListItem{
+		Id:          attachment.AttachmentId,
+		QuestionId:  attachment.QuestionId,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		CreatedAt:   attachment.CreatedAt,
+		Url:         config.GetString("PUBLIC_BASE_URL", "") + "/v1/attachment/" + attachment.AttachmentId + "?" + query.Encode(),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+func findAttachment(ctx context.Context, attachment *Attachment) error {
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Model(&Attachment{}).Where(attachment).First(attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &AttachmentNotFoundError{}
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// OpenAttachment 校验下载链接的签名，并打开附件供下载。调用方需要关闭返回的Reader。
+//
+// ctx 是上下文。
+// attachmentId 是附件的唯一标识符。
+// request 是下载链接中的签名参数。
+func OpenAttachment(ctx context.Context, attachmentId string, request *DownloadAttachmentRequest) (*Attachment, io.ReadCloser, error) {
+	slog.Debug("model.OpenAttachment: 正在打开附件", "attachmentId", attachmentId)
+	err := sign.Verify(attachmentId, request.Expires, request.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	attachment := Attachment{AttachmentId: attachmentId}
+	err = findAttachment(ctx, &attachment)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv := service.GetService()
+	reader, err := srv.Storage.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		slog.Error("调用存储服务失败。", "error", err)
+		return nil, nil, &AttachmentNotFoundError{}
+	}
+	return &attachment, reader, nil
+}
+
+// DeleteAttachment 删除用户自己的附件。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// attachmentId 是附件的唯一标识符。
+func DeleteAttachment(ctx context.Context, openid string, attachmentId string) error {
+	slog.Debug("model.DeleteAttachment: 正在删除附件", "openid", openid, "attachmentId", attachmentId)
+	attachment := Attachment{AttachmentId: attachmentId, OpenId: openid}
+	err := findAttachment(ctx, &attachment)
+	if err != nil {
+		return err
+	}
+	removeAttachment(ctx, &attachment)
+	return nil
+}
+
+// DeleteAllAttachments 删除用户的全部附件。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func DeleteAllAttachments(ctx context.Context, openid string) {
+	slog.Debug("model.DeleteAllAttachments: 正在删除用户的全部附件", "openid", openid)
+	for _, v := range findAttachmentList(ctx, openid, "") {
+		removeAttachment(ctx, &v)
+	}
+}
+
+// removeAttachment 删除附件记录与存储中的文件。
+func removeAttachment(ctx context.Context, attachment *Attachment) {
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Unscoped().Delete(attachment).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	err = srv.Storage.Delete(ctx, attachment.StorageKey)
+	if err != nil {
+		// 记录已删除，残留的文件不会再被访问
+		slog.Error("调用存储服务失败。", "error", err, "key", attachment.StorageKey)
+	}
+}
@@ -0,0 +1,288 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/request"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+const (
+	// AuditActorSystem 是后台任务等没有登录用户的操作的执行者。
+	AuditActorSystem = "system"
+)
+
+const (
+	AuditTargetQuestion  = "question"
+	AuditTargetSection   = "section"
+	AuditTargetGroup     = "group"
+	AuditTargetRoom      = "room"
+	AuditTargetTicket    = "ticket"
+	AuditTargetSelection = "selection"
+	AuditTargetAccount   = "account"
+	AuditTargetNotice    = "notice"
+	AuditTargetRetention = "retention"
+	AuditTargetOutbox    = "outbox"
+	AuditTargetWebhook   = "webhook"
+)
+
+// AuditLog 是工作人员操作与敏感的用户操作的审计记录。
+// 审计记录只会追加，不会被删除，只有保留策略会移除其中超过期限的个人信息，见RunRetentionPolicy。
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	// Actor 是执行操作的用户的Subject，后台任务为“system”。
+	Actor string `gorm:"type:varchar(64);index"`
+	// Action 是操作的名称，如“room.update”。
+	Action string `gorm:"type:varchar(64);index"`
+	// TargetType 是被操作对象的类型。
+	TargetType string `gorm:"type:varchar(32);index:idx_audit_log_target"`
+	// TargetId 是被操作对象的唯一标识符。
+	TargetId string `gorm:"type:varchar(64);index:idx_audit_log_target"`
+	// Before 是操作前对象的JSON，创建时为空。
+	Before string `gorm:"type:text"`
+	// After 是操作后对象的JSON，删除时为空。
+	After string `gorm:"type:text"`
+	// Diff 是发生变化的字段的JSON，每个字段包含before与after。
+	Diff string `gorm:"type:text"`
+	// RequestId 是操作所属请求的请求ID。
+	RequestId string `gorm:"type:varchar(64);index"`
+}
+
+// AuditLogListItem 是审计记录列表项。
+type AuditLogListItem struct {
+	// Id 是审计记录的唯一标识符。
+	Id uint `json:"id"`
+	// Actor 是执行操作的用户。
+	Actor string `json:"actor"`
+	// Action 是操作的名称。
+	Action string `json:"action"`
+	// TargetType 是被操作对象的类型。
+	TargetType string `json:"target_type"`
+	// TargetId 是被操作对象的唯一标识符。
+	TargetId string `json:"target_id"`
+	// Before 是操作前的对象。
+	Before json.RawMessage `json:"before"`
+	// After 是操作后的对象。
+	After json.RawMessage `json:"after"`
+	// Diff 是发生变化的字段。
+	Diff json.RawMessage `json:"diff"`
+	// RequestId 是操作所属请求的请求ID。
+	RequestId string `json:"request_id"`
+	// CreatedAt 是操作的时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetAuditLogListResponse 是查询审计记录的响应。
+type GetAuditLogListResponse struct {
+	Logs []AuditLogListItem `json:"logs"`
+	// NextCursor 是获取下一页时使用的cursor，没有更多记录时为0。
+	NextCursor uint `json:"next_cursor"`
+}
+
+// AuditLogQuery 是查询审计记录的条件。
+type AuditLogQuery struct {
+	// Actor 是执行操作的用户。
+	Actor string `form:"actor"`
+	// Action 是操作的名称。
+	Action string `form:"action"`
+	// TargetType 是被操作对象的类型。
+	TargetType string `form:"target_type"`
+	// TargetId 是被操作对象的唯一标识符。
+	TargetId string `form:"target_id"`
+	// RequestId 是请求ID。
+	RequestId string `form:"request_id"`
+	// Since 是起始时间，格式为RFC3339。
+	Since *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Until 是结束时间，格式为RFC3339。
+	Until *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Cursor 是上一页返回的NextCursor，只返回比它更早的记录。
+	Cursor uint `form:"cursor"`
+	// Limit 是返回的最大条数，默认为100。
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// auditIgnoredFields 是计算差异时忽略的字段，它们在每次保存时都会变化。
+var auditIgnoredFields = map[string]bool{
+	"ID":        true,
+	"CreatedAt": true,
+	"UpdatedAt": true,
+	"DeletedAt": true,
+}
+
+// createAuditLog 在事务中记录一次操作。
+// 执行者与请求ID从上下文中获取，before与after会被序列化为JSON，为nil时表示对象不存在。
+//
+// tx 是当前事务。
+// action 是操作的名称。
+// targetType 是被操作对象的类型。
+// targetId 是被操作对象的唯一标识符。
+// before 是操作前的对象。
+// after 是操作后的对象。
+func createAuditLog(ctx context.Context, tx *gorm.DB, action string, targetType string, targetId string, before interface{}, after interface{}) error {
+	actor := request.GetActor(ctx)
+	if actor == "" {
+		actor = AuditActorSystem
+	}
+	slog.Debug("model.createAuditLog: 正在记录审计日志", "actor", actor, "action", action, "targetId", targetId)
+	beforeValue, beforeMap := marshalAuditValue(before)
+	afterValue, afterMap := marshalAuditValue(after)
+	diff := make(map[string]map[string]interface{})
+	for key := range beforeMap {
+		if _, ok := afterMap[key]; !ok {
+			afterMap[key] = nil
+		}
+	}
+	for key, value := range afterMap {
+		if auditIgnoredFields[key] || reflect.DeepEqual(beforeMap[key], value) {
+			continue
+		}
+		diff[key] = map[string]interface{}{
+			"before": beforeMap[key],
+			"after":  value,
+		}
+	}
+	diffValue, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Create(&AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Before:     beforeValue,
+		After:      afterValue,
+		Diff:       string(diffValue),
+		RequestId:  request.GetRequestId(ctx),
+	}).Error
+}
+
+// removeAuditField 从审计记录的JSON中移除字段，为空或无法解析时原样返回。
+//
+// value 是Before、After或Diff的JSON。
+// field 是字段的JSON名称。
+func removeAuditField(value string, field string) string {
+	var object map[string]json.RawMessage
+	if value == "" || json.Unmarshal([]byte(value), &object) != nil {
+		return value
+	}
+	if _, ok := object[field]; !ok {
+		return value
+	}
+	delete(object, field)
+	result, err := json.Marshal(object)
+	if err != nil {
+		return value
+	}
+	return string(result)
+}
+
+// recordAuditLog 在事务之外记录一次操作，参数见createAuditLog。
+func recordAuditLog(ctx context.Context, action string, targetType string, targetId string, before interface{}, after interface{}) {
+	srv := service.GetService()
+	err := createAuditLog(ctx, srv.DB, action, targetType, targetId, before, after)
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
+
+// marshalAuditValue 将对象序列化为JSON，并展开为字段的映射用于计算差异。
+func marshalAuditValue(value interface{}) (string, map[string]interface{}) {
+	fields := make(map[string]interface{})
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return "", fields
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("model.marshalAuditValue: 无法序列化审计对象", "error", err)
+		panic(err)
+	}
+	// 非对象的值没有字段，只记录整体
+	_ = json.Unmarshal(data, &fields)
+	return string(data), fields
+}
+
+// findAuditSnapshot 获取对象当前的状态，用作审计记录中的before或after，不存在时返回nil。
+//
+// dest 是用于接收结果的模型指针。
+// where 是查询条件。
+func findAuditSnapshot[T any](ctx context.Context, dest *T, where interface{}) *T {
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Where(where).First(dest).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return dest
+}
+
+// QueryAuditLog 按条件查询审计记录，按时间倒序排列。
+//
+// ctx 是上下文。
+// query 是查询条件。
+func QueryAuditLog(ctx context.Context, query *AuditLogQuery) *GetAuditLogListResponse {
+	slog.Debug("model.QueryAuditLog: 正在查询审计记录", "query", query)
+	srv := service.GetService()
+	limit := query.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	db := srv.DB.WithContext(ctx).Model(&AuditLog{}).Where(&AuditLog{
+		Actor:      query.Actor,
+		Action:     query.Action,
+		TargetType: query.TargetType,
+		TargetId:   query.TargetId,
+		RequestId:  query.RequestId,
+	})
+	if query.Since != nil {
+		db = db.Where("created_at >= ?", *query.Since)
+	}
+	if query.Until != nil {
+		db = db.Where("created_at < ?", *query.Until)
+	}
+	if query.Cursor > 0 {
+		db = db.Where("id < ?", query.Cursor)
+	}
+	var logs []AuditLog
+	err := db.Order("id DESC").Limit(limit).Find(&logs).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetAuditLogListResponse{Logs: make([]AuditLogListItem, 0, len(logs))}
+	for _, v := range logs {
+		result.Logs = append(result.Logs, AuditLogListItem{
+			Id:         v.ID,
+			Actor:      v.Actor,
+			Action:     v.Action,
+			TargetType: v.TargetType,
+			TargetId:   v.TargetId,
+			Before:     rawAuditValue(v.Before),
+			After:      rawAuditValue(v.After),
+			Diff:       rawAuditValue(v.Diff),
+			RequestId:  v.RequestId,
+			CreatedAt:  v.CreatedAt,
+		})
+	}
+	if len(logs) == limit {
+		result.NextCursor = logs[len(logs)-1].ID
+	}
+	return &result
+}
+
+func rawAuditValue(value string) json.RawMessage {
+	if value == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(value)
+}
@@ -0,0 +1,121 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"elab-backend/util/ical"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RoomTimeNotSetError struct{}
+
+func (e *RoomTimeNotSetError) Error() string {
+	return "房间尚未安排面试时间"
+}
+
+// GetInterviewDuration 获取未设置结束时间的房间的面试时长，由INTERVIEW_DURATION指定，默认为30分钟。
+func GetInterviewDuration() time.Duration {
+	return config.GetDuration("INTERVIEW_DURATION", 30*time.Minute)
+}
+
+// newRoomEvent 根据房间创建日历事件，房间未安排时间时返回nil。
+//
+// room 是房间。
+// uid 是事件的唯一标识符。
+// description 是事件的描述。
+func newRoomEvent(room *Room, uid string, description string) *ical.Event {
+	if room.Time == nil {
+		return nil
+	}
+	return &ical.Event{
+		Uid:         uid + "@elab-backend",
+		Summary:     "E-Lab 面试：" + room.Name,
+		Description: description,
+		Location:    room.Location,
+		Start:       *room.Time,
+		End:         *room.GetEnd(),
+		UpdatedAt:   room.UpdatedAt,
+	}
+}
+
+// GetSelectionCalendar 获取用户所选面试场次的日历。
+// 用户未选择房间时返回*SelectionNotFoundError，房间未安排时间时返回*RoomTimeNotSetError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func GetSelectionCalendar(ctx context.Context, openid string) (*ical.Calendar, error) {
+	slog.Debug("model.GetSelectionCalendar: 正在生成面试日历", "openid", openid)
+	selection, err := GetSelection(ctx, openid)
+	if err != nil {
+		return nil, err
+	}
+	room := findRoom(ctx, selection.RoomId)
+	if room == nil {
+		return nil, &SelectionNotFoundError{}
+	}
+	event := newRoomEvent(room, "selection-"+room.RoomId, "如需更改面试时间，请在面试开始前登录重新选择。")
+	if event == nil {
+		return nil, &RoomTimeNotSetError{}
+	}
+	return &ical.Calendar{
+		Name:   "E-Lab 面试",
+		Events: []ical.Event{*event},
+	}, nil
+}
+
+// GetRoomCalendar 获取房间的面试日历，事件描述中列出已选择该房间的全部面试者，供工作人员使用。
+// 房间不存在时返回*RoomNotFoundError，房间未安排时间时返回*RoomTimeNotSetError。
+//
+// ctx 是上下文。
+// roomId 是房间的唯一标识符。
+func GetRoomCalendar(ctx context.Context, roomId string) (*ical.Calendar, error) {
+	slog.Debug("model.GetRoomCalendar: 正在生成房间日历", "roomId", roomId)
+	room := findRoom(ctx, roomId)
+	if room == nil {
+		return nil, &RoomNotFoundError{}
+	}
+	srv := service.GetService()
+	var tickets []Ticket
+	err := srv.DB.WithContext(ctx).Model(&Ticket{}).
+		Joins("JOIN selections ON selections.open_id = tickets.open_id AND selections.deleted_at IS NULL").
+		Where("selections.room_id = ?", roomId).Order("selections.created_at").Find(&tickets).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	// 同一用户可能有多行申请表，每个用户只列出一次
+	seen := make(map[string]bool)
+	applicants := tickets[:0]
+	for _, v := range tickets {
+		if seen[v.OpenId] {
+			continue
+		}
+		seen[v.OpenId] = true
+		applicants = append(applicants, v)
+	}
+	tickets = applicants
+	groupNames := make(map[string]string)
+	for _, v := range GetGroupList(ctx).Groups {
+		groupNames[v.Id] = v.Name
+	}
+	lines := []string{"面试者（" + strconv.Itoa(len(tickets)) + "/" + strconv.Itoa(room.Capacity) + "）："}
+	for i, v := range tickets {
+		group := groupNames[v.Group]
+		if group == "" {
+			group = v.Group
+		}
+		lines = append(lines, strconv.Itoa(i+1)+". "+v.Name+"（"+v.StudentId+"，"+group+"）")
+	}
+	event := newRoomEvent(room, "room-"+room.RoomId, strings.Join(lines, "\n"))
+	if event == nil {
+		return nil, &RoomTimeNotSetError{}
+	}
+	return &ical.Calendar{
+		Name:   "E-Lab 面试：" + room.Name,
+		Events: []ical.Event{*event},
+	}, nil
+}
@@ -0,0 +1,76 @@
+package apply
+
+import (
+	"elab-backend/util/config"
+	"log/slog"
+	"sync"
+	"time"
+	// 内置时区数据，Alpine镜像中默认没有tzdata
+	_ "time/tzdata"
+)
+
+var (
+	campaignLocation     *time.Location
+	campaignLocationOnce sync.Once
+)
+
+type ApplicationClosedError struct{}
+
+func (e *ApplicationClosedError) Error() string {
+	return "申请已截止"
+}
+
+type InvalidDateError struct{}
+
+func (e *InvalidDateError) Error() string {
+	return "日期格式错误，应为YYYY-MM-DD"
+}
+
+// GetCampaign 获取当前招新批次的标识，由APPLY_CAMPAIGN指定，如“2023-autumn”。
+// 同一学号在同一批次中只能提交一份申请。
+func GetCampaign() string {
+	return config.GetString("APPLY_CAMPAIGN", "default")
+}
+
+// GetDeadline 获取当前批次的申请截止时间，由APPLY_DEADLINE指定，未设置时返回nil。
+func GetDeadline() *time.Time {
+	return config.GetTime("APPLY_DEADLINE", nil)
+}
+
+// IsApplicationClosed 检查当前批次是否已经截止申请。
+func IsApplicationClosed() bool {
+	deadline := GetDeadline()
+	return deadline != nil && time.Now().After(*deadline)
+}
+
+// GetCampaignLocation 获取招新所在的时区，由CAMPAIGN_TIMEZONE指定，默认为“Asia/Shanghai”。
+// 面试日期的划分与返回给用户的时间都使用该时区，与服务器和数据库的时区无关。
+func GetCampaignLocation() *time.Location {
+	campaignLocationOnce.Do(func() {
+		name := config.GetString("CAMPAIGN_TIMEZONE", "Asia/Shanghai")
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			slog.Error("model.GetCampaignLocation: 无法加载时区", "timezone", name, "error", err)
+			panic(err)
+		}
+		campaignLocation = location
+	})
+	return campaignLocation
+}
+
+// ParseCampaignDate 解析招新时区中的日期，返回当天的开始时间与第二天的开始时间。
+// 日期格式不是“YYYY-MM-DD”时返回*InvalidDateError。
+//
+// date 是日期。
+func ParseCampaignDate(date string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02", date, GetCampaignLocation())
+	if err != nil {
+		return time.Time{}, time.Time{}, &InvalidDateError{}
+	}
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+// FormatCampaignTime 将时间格式化为招新时区中的“YYYY-MM-DD HH:MM”，用于通知等展示场景。
+func FormatCampaignTime(t time.Time) string {
+	return t.In(GetCampaignLocation()).Format("2006-01-02 15:04")
+}
@@ -0,0 +1,238 @@
+package apply
+
+import (
+	"strings"
+)
+
+const (
+	// ConditionSourceAnswer 表示条件取决于其他问题的回答。
+	ConditionSourceAnswer = "answer"
+	// ConditionSourceTicket 表示条件取决于申请表的字段。
+	ConditionSourceTicket = "ticket"
+)
+
+// QuestionVisibility 是问题的显示条件。
+type QuestionVisibility struct {
+	// Match 是条件的组合方式，“all”表示全部满足，“any”表示满足任意一个，默认为“all”。
+	Match string `json:"match" binding:"omitempty,oneof=all any"`
+	// Conditions 是条件列表。
+	Conditions []QuestionCondition `json:"conditions" binding:"dive"`
+}
+
+// QuestionCondition 是单个显示条件。
+type QuestionCondition struct {
+	// Source 是条件的来源，为“answer”或“ticket”。
+	Source string `json:"source" binding:"required,oneof=answer ticket"`
+	// Field 在来源为answer时是问题ID，为ticket时是申请表字段，
+	// 如“name”、“student_id”、“class_name”、“group”、“contact”，其中“group”只是第一志愿，
+	// “preferences”是全部志愿组别，任一志愿满足即可，“ne”则要求所有志愿都不相等。
+	Field string `json:"field" binding:"required"`
+	// Operator 是比较方式，为“eq”、“ne”、“in”、“contains”或“not_empty”。
+	Operator string `json:"operator" binding:"required,oneof=eq ne in contains not_empty"`
+	// Values 是用于比较的值，“eq”、“ne”、“contains”只使用第一个值。
+	Values []string `json:"values"`
+}
+
+type InvalidConditionError struct {
+	// Reason 是条件无效的原因。
+	Reason string
+}
+
+func (e *InvalidConditionError) Error() string {
+	return "显示条件无效：" + e.Reason
+}
+
+// conditionPreferencesField 是条件中引用全部志愿组别的字段，志愿有多个，因此不在conditionTicketFields中。
+const conditionPreferencesField = "preferences"
+
+// conditionTicketFields 是条件中可以引用的申请表字段。
+var conditionTicketFields = map[string]func(ticket *Ticket) string{
+	"name":       func(ticket *Ticket) string { return ticket.Name },
+	"student_id": func(ticket *Ticket) string { return ticket.StudentId },
+	"class_name": func(ticket *Ticket) string { return ticket.ClassName },
+	"group":      func(ticket *Ticket) string { return ticket.Group },
+	"contact":    func(ticket *Ticket) string { return ticket.Contact },
+}
+
+// conditionEnv 是计算显示条件所需的数据。
+type conditionEnv struct {
+	// ticket 是用户的申请表，可能为nil。
+	ticket *Ticket
+	// groups 是用户按顺位排列的志愿组别。
+	groups []string
+	// answers 是用户对各问题的回答。
+	answers map[string]string
+	// visible 是当前认为可见的问题。
+	visible map[string]bool
+}
+
+// validate 检查显示条件是否有效，包括是否与其他问题的条件形成循环引用。
+//
+// questionId 是条件所属的问题ID。
+// conditions 是现有的全部问题的显示条件，以问题ID为键，也用于检查被引用的问题是否存在。
+func (v *QuestionVisibility) validate(questionId string, conditions map[string]*QuestionVisibility) error {
+	if v == nil {
+		return nil
+	}
+	for _, c := range v.Conditions {
+		switch c.Source {
+		case ConditionSourceAnswer:
+			if c.Field == questionId {
+				return &InvalidConditionError{Reason: "问题不能引用自身"}
+			}
+			if _, ok := conditions[c.Field]; !ok {
+				return &InvalidConditionError{Reason: "引用的问题不存在"}
+			}
+			if dependsOn(c.Field, questionId, conditions, make(map[string]bool)) {
+				return &InvalidConditionError{Reason: "问题之间不能循环引用"}
+			}
+		case ConditionSourceTicket:
+			if _, ok := conditionTicketFields[c.Field]; !ok && c.Field != conditionPreferencesField {
+				return &InvalidConditionError{Reason: "引用的申请表字段不存在"}
+			}
+		}
+		if c.Operator != "not_empty" && len(c.Values) == 0 {
+			return &InvalidConditionError{Reason: "缺少比较的值"}
+		}
+	}
+	return nil
+}
+
+// dependsOn 检查问题的显示条件是否直接或间接引用了target。
+// 现有的条件在保存时都经过了检查，不会有循环，visited只用于避免重复访问。
+func dependsOn(questionId string, target string, conditions map[string]*QuestionVisibility, visited map[string]bool) bool {
+	if visited[questionId] {
+		return false
+	}
+	visited[questionId] = true
+	visibility := conditions[questionId]
+	if visibility == nil {
+		return false
+	}
+	for _, c := range visibility.Conditions {
+		if c.Source != ConditionSourceAnswer {
+			continue
+		}
+		if c.Field == target || dependsOn(c.Field, target, conditions, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate 计算显示条件是否满足，没有条件时总是满足。
+func (v *QuestionVisibility) evaluate(env *conditionEnv) bool {
+	if v == nil || len(v.Conditions) == 0 {
+		return true
+	}
+	matchAny := v.Match == "any"
+	for _, c := range v.Conditions {
+		ok := c.evaluate(env)
+		if matchAny && ok {
+			return true
+		}
+		if !matchAny && !ok {
+			return false
+		}
+	}
+	return !matchAny
+}
+
+func (c *QuestionCondition) evaluate(env *conditionEnv) bool {
+	if c.Source == ConditionSourceTicket && c.Field == conditionPreferencesField {
+		return c.matchAny(env.groups)
+	}
+	var value string
+	switch c.Source {
+	case ConditionSourceAnswer:
+		// 被隐藏的问题的回答视为空
+		if env.visible[c.Field] {
+			value = env.answers[c.Field]
+		}
+	case ConditionSourceTicket:
+		if getter, ok := conditionTicketFields[c.Field]; ok && env.ticket != nil {
+			value = getter(env.ticket)
+		}
+	}
+	return c.match(value)
+}
+
+// matchAny 计算多值字段是否满足条件，任一值满足即可，“ne”则要求所有值都不相等。
+func (c *QuestionCondition) matchAny(values []string) bool {
+	switch c.Operator {
+	case "ne":
+		for _, v := range values {
+			if !c.match(v) {
+				return false
+			}
+		}
+		return true
+	case "not_empty":
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if c.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// match 计算单个值是否满足条件。
+func (c *QuestionCondition) match(value string) bool {
+	value = strings.TrimSpace(value)
+	var first string
+	if len(c.Values) > 0 {
+		first = c.Values[0]
+	}
+	switch c.Operator {
+	case "eq":
+		return value == first
+	case "ne":
+		return value != first
+	case "in":
+		for _, v := range c.Values {
+			if value == v {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		return strings.Contains(value, first)
+	case "not_empty":
+		return value != ""
+	}
+	return false
+}
+
+// filterByCondition 根据显示条件筛选问题。
+// 条件可以引用其他带条件的问题，因此反复计算直到结果不再变化。
+//
+// questions 是候选问题列表。
+// env 是计算条件所需的数据，其中visible会被更新为最终结果。
+func filterByCondition(questions []Question, env *conditionEnv) []Question {
+	env.visible = make(map[string]bool, len(questions))
+	for _, q := range questions {
+		env.visible[q.QuestionId] = true
+	}
+	for i := 0; i <= len(questions); i++ {
+		changed := false
+		for _, q := range questions {
+			visible := q.VisibleWhen.evaluate(env)
+			if env.visible[q.QuestionId] != visible {
+				env.visible[q.QuestionId] = visible
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	result := make([]Question, 0, len(questions))
+	for _, q := range questions {
+		if env.visible[q.QuestionId] {
+			result = append(result, q)
+		}
+	}
+	return result
+}
@@ -0,0 +1,306 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DeletionStatusPending 表示账号正在等待删除，宽限期内可以撤销。
+	DeletionStatusPending = "pending"
+	// DeletionStatusCancelled 表示用户撤销了删除。
+	DeletionStatusCancelled = "cancelled"
+	// DeletionStatusCompleted 表示数据与身份提供方中的用户都已删除。
+	DeletionStatusCompleted = "completed"
+	// DeletionStatusFailed 表示重试次数用尽，需要工作人员处理。
+	DeletionStatusFailed = "failed"
+)
+
+const (
+	// NoticeTypeDeletionFailed 是账号删除失败的通知。
+	NoticeTypeDeletionFailed = "deletion_failed"
+)
+
+// AccountDeletion 是账号删除任务。
+// 用户申请删除后先进入宽限期，到期后由定时任务清除数据并删除身份提供方中的用户。
+type AccountDeletion struct {
+	gorm.Model
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// Status 是任务的状态。
+	Status string `gorm:"type:varchar(16);index"`
+	// ScheduledAt 是宽限期结束、开始删除的时间。
+	ScheduledAt time.Time `gorm:"type:datetime"`
+	// NextAttemptAt 是下一次尝试删除的时间。
+	NextAttemptAt time.Time `gorm:"type:datetime;index"`
+	// Attempts 是已经尝试的次数。
+	Attempts int `gorm:"type:int;default:0"`
+	// LastError 是最近一次失败的原因。
+	LastError string `gorm:"type:text"`
+	// DataPurgedAt 是数据库中数据被清除的时间。
+	DataPurgedAt *time.Time `gorm:"type:datetime"`
+	// CompletedAt 是任务完成的时间。
+	CompletedAt *time.Time `gorm:"type:datetime"`
+}
+
+// AccountDeletionResponse 是账号删除任务的状态。
+type AccountDeletionResponse struct {
+	// Status 是任务的状态，没有任务时为空。
+	Status string `json:"status"`
+	// ScheduledAt 是开始删除的时间。
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+type DeletionNotFoundError struct{}
+
+func (e *DeletionNotFoundError) Error() string {
+	return "没有可以撤销的删除申请"
+}
+
+// GetDeletionGracePeriod 获取删除账号的宽限期，由ACCOUNT_DELETION_GRACE_PERIOD指定，默认为7天。
+func GetDeletionGracePeriod() time.Duration {
+	return config.GetDuration("ACCOUNT_DELETION_GRACE_PERIOD", 7*24*time.Hour)
+}
+
+// getDeletionMaxAttempts 获取删除账号的最大尝试次数。
+func getDeletionMaxAttempts() int {
+	return int(config.GetInt("ACCOUNT_DELETION_MAX_ATTEMPTS", 5))
+}
+
+// findPendingDeletion 获取用户正在等待的删除任务，不存在时返回nil。
+func findPendingDeletion(ctx context.Context, openid string) *AccountDeletion {
+	srv := service.GetService()
+	var deletion AccountDeletion
+	err := srv.DB.WithContext(ctx).Model(&AccountDeletion{}).Where(&AccountDeletion{
+		OpenId: openid,
+		Status: DeletionStatusPending,
+	}).First(&deletion).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &deletion
+}
+
+// GetAccountDeletion 获取用户的账号删除任务。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func GetAccountDeletion(ctx context.Context, openid string) *AccountDeletionResponse {
+	slog.Debug("model.GetAccountDeletion: 正在获取删除任务", "openid", openid)
+	deletion := findPendingDeletion(ctx, openid)
+	if deletion == nil {
+		return &AccountDeletionResponse{}
+	}
+	return &AccountDeletionResponse{
+		Status:      deletion.Status,
+		ScheduledAt: &deletion.ScheduledAt,
+	}
+}
+
+// RequestAccountDeletion 申请删除账号，宽限期结束后才会真正删除。重复申请时返回已有的任务。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func RequestAccountDeletion(ctx context.Context, openid string) *AccountDeletionResponse {
+	slog.Debug("model.RequestAccountDeletion: 正在申请删除账号", "openid", openid)
+	if findPendingDeletion(ctx, openid) == nil {
+		srv := service.GetService()
+		scheduledAt := time.Now().Add(GetDeletionGracePeriod())
+		deletion := AccountDeletion{
+			OpenId:        openid,
+			Status:        DeletionStatusPending,
+			ScheduledAt:   scheduledAt,
+			NextAttemptAt: scheduledAt,
+		}
+		err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Create(&deletion).Error
+			if err != nil {
+				return err
+			}
+			return createAuditLog(ctx, tx, "account.deletion.request", AuditTargetAccount, openid, nil, &deletion)
+		})
+		if err != nil {
+			slog.Error("调用ORM失败。", "error", err)
+			panic(err)
+		}
+	}
+	return GetAccountDeletion(ctx, openid)
+}
+
+// CancelAccountDeletion 在宽限期内撤销删除账号的申请。
+// 宽限期已过或没有申请时返回*DeletionNotFoundError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func CancelAccountDeletion(ctx context.Context, openid string) error {
+	slog.Debug("model.CancelAccountDeletion: 正在撤销删除账号", "openid", openid)
+	srv := service.GetService()
+	result := srv.DB.WithContext(ctx).Model(&AccountDeletion{}).Where(&AccountDeletion{
+		OpenId: openid,
+		Status: DeletionStatusPending,
+	}).Where("scheduled_at > ?", time.Now()).
+		// 已经开始删除的任务不能撤销
+		Where("attempts = 0 AND data_purged_at IS NULL").Updates(&AccountDeletion{
+		Status: DeletionStatusCancelled,
+	})
+	if result.Error != nil {
+		slog.Error("调用ORM失败。", "error", result.Error)
+		panic(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return &DeletionNotFoundError{}
+	}
+	recordAuditLog(ctx, "account.deletion.cancel", AuditTargetAccount, openid, nil, nil)
+	return nil
+}
+
+// GetDueAccountDeletions 获取已经到期、需要执行的删除任务。
+//
+// ctx 是上下文。
+func GetDueAccountDeletions(ctx context.Context) []AccountDeletion {
+	srv := service.GetService()
+	var deletions []AccountDeletion
+	err := srv.DB.WithContext(ctx).Model(&AccountDeletion{}).Where(&AccountDeletion{
+		Status: DeletionStatusPending,
+	}).Where("next_attempt_at <= ?", time.Now()).Order("id").Find(&deletions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return deletions
+}
+
+// PurgeAccountData 在一个事务中彻底删除用户在数据库中的全部数据，并释放其选择的房间。
+// 附件文件在事务提交后删除。该函数可以重复执行。调用方需要持有房间选择的锁。
+//
+// ctx 是上下文。
+// deletion 是删除任务，成功后会记录清除时间。
+func PurgeAccountData(ctx context.Context, deletion *AccountDeletion) error {
+	openid := deletion.OpenId
+	slog.Debug("model.PurgeAccountData: 正在清除用户数据", "openid", openid)
+	srv := service.GetService()
+	attachments := findAttachmentList(ctx, openid, "")
+	now := time.Now()
+	var roomIds []string
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var selections []Selection
+		err := tx.Model(&Selection{}).Where(&Selection{OpenId: openid}).Find(&selections).Error
+		if err != nil {
+			return err
+		}
+		for _, v := range selections {
+			roomIds = append(roomIds, v.RoomId)
+			err := tx.Model(&Room{}).Where(&Room{RoomId: v.RoomId}).Where("occupancy > 0").
+				Update("occupancy", gorm.Expr("occupancy - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+		heldRoomId, err := releaseSeatHold(tx, openid)
+		if err != nil {
+			return err
+		}
+		roomIds = append(roomIds, heldRoomId)
+		// 投递记录的请求正文中含有OpenId
+		err = tx.Where("event_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&OutboxEvent{}).
+			Select("id").Where("open_id = ?", openid)).Delete(&WebhookDelivery{}).Error
+		if err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&Selection{}, &Ticket{}, &TicketPreference{}, &TextForm{}, &Revision{}, &Attachment{}, &StaffNotice{},
+			&OutboxEvent{}, &InterviewReminder{},
+		} {
+			err := tx.Unscoped().Where("open_id = ?", openid).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+		deletion.DataPurgedAt = &now
+		return tx.Model(deletion).Updates(&AccountDeletion{DataPurgedAt: &now}).Error
+	})
+	if err != nil {
+		return err
+	}
+	publishRoomOccupancy(ctx, roomIds...)
+	for _, v := range attachments {
+		err := srv.Storage.Delete(ctx, v.StorageKey)
+		if err != nil {
+			// 记录已删除，残留的文件不会再被访问
+			slog.Error("调用存储服务失败。", "error", err, "key", v.StorageKey)
+		}
+	}
+	return nil
+}
+
+// CompleteAccountDeletion 记录删除任务已经完成。
+//
+// ctx 是上下文。
+// deletion 是删除任务。
+func CompleteAccountDeletion(ctx context.Context, deletion *AccountDeletion) {
+	slog.Info("model.CompleteAccountDeletion: 账号已删除", "openid", deletion.OpenId)
+	srv := service.GetService()
+	now := time.Now()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before := *deletion
+		deletion.Status = DeletionStatusCompleted
+		deletion.Attempts++
+		deletion.CompletedAt = &now
+		err := tx.Model(deletion).Select("status", "attempts", "completed_at").Updates(deletion).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "account.deletion.complete", AuditTargetAccount, deletion.OpenId, &before, deletion)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
+
+// FailAccountDeletion 记录删除任务的一次失败，并安排重试。
+// 重试间隔随次数指数增长，次数用尽后标记为失败并通知工作人员。
+//
+// ctx 是上下文。
+// deletion 是删除任务。
+// cause 是失败的原因。
+func FailAccountDeletion(ctx context.Context, deletion *AccountDeletion, cause error) {
+	slog.Error("model.FailAccountDeletion: 删除账号失败", "openid", deletion.OpenId, "attempts", deletion.Attempts+1, "error", cause)
+	srv := service.GetService()
+	attempts := deletion.Attempts + 1
+	update := AccountDeletion{
+		Attempts:      attempts,
+		LastError:     cause.Error(),
+		NextAttemptAt: time.Now().Add(time.Minute << attempts),
+	}
+	before := *deletion
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if attempts >= getDeletionMaxAttempts() {
+			update.Status = DeletionStatusFailed
+			err := createStaffNotice(ctx, tx, NoticeTypeDeletionFailed, deletion.OpenId,
+				"账号删除多次失败，需要人工处理："+cause.Error())
+			if err != nil {
+				return err
+			}
+		}
+		err := tx.Model(deletion).Updates(&update).Error
+		if err != nil {
+			return err
+		}
+		// Updates会把更新的字段写回deletion
+		return createAuditLog(ctx, tx, "account.deletion.fail", AuditTargetAccount, deletion.OpenId, &before, deletion)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
@@ -0,0 +1,151 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DuplicateReasonStudentId 表示学号相同。
+	DuplicateReasonStudentId = "student_id"
+	// DuplicateReasonContact 表示联系方式相同。
+	DuplicateReasonContact = "contact"
+	// DuplicateReasonNameClass 表示姓名和班级都相同。
+	DuplicateReasonNameClass = "name_class"
+)
+
+// TicketAdminItem 是管理员查看的申请表。
+type TicketAdminItem struct {
+	TicketBody
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// Campaign 是申请表所属的招新批次。
+	Campaign string `json:"campaign"`
+	// OfferGroup 是最终录取的组别ID，为空表示尚未录取。
+	OfferGroup string `json:"offer_group"`
+	// Withdrawn 是申请是否已被撤回。
+	Withdrawn bool `json:"withdrawn"`
+	// WithdrawReason 是撤回时填写的原因。
+	WithdrawReason string `json:"withdraw_reason,omitempty"`
+	// UpdatedAt 是申请表最后一次更新的时间。
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DuplicateGroup 是一组疑似重复的申请。
+type DuplicateGroup struct {
+	// Reason 是判断为重复的原因。
+	Reason string `json:"reason"`
+	// Value 是重复的值。
+	Value string `json:"value"`
+	// Tickets 是这一组中的申请表。
+	Tickets []TicketAdminItem `json:"tickets"`
+}
+
+// GetDuplicateReportResponse 是疑似重复申请报告的响应。
+type GetDuplicateReportResponse struct {
+	// Campaign 是报告所针对的招新批次。
+	Campaign string `json:"campaign"`
+	// Duplicates 是疑似重复的申请分组。
+	Duplicates []DuplicateGroup `json:"duplicates"`
+}
+
+// newTicketAdminItem 生成管理员查看的申请表。
+//
+// ticket 是申请表记录。
+// preferences 是批量获取的志愿，见getTicketPreferenceMap。
+func newTicketAdminItem(ticket *Ticket, preferences map[string][]string) TicketAdminItem {
+	groups := preferences[ticket.OpenId]
+	if len(groups) == 0 && ticket.Group != "" {
+		groups = []string{ticket.Group}
+	}
+	return TicketAdminItem{
+		TicketBody: TicketBody{
+			Name:        ticket.Name,
+			StudentId:   ticket.StudentId,
+			ClassName:   ticket.ClassName,
+			Group:       ticket.Group,
+			Contact:     ticket.Contact,
+			Preferences: groups,
+		},
+		OpenId:         ticket.OpenId,
+		Campaign:       ticket.Campaign,
+		OfferGroup:     ticket.OfferGroup,
+		Withdrawn:      ticket.Withdrawn != nil && *ticket.Withdrawn,
+		WithdrawReason: ticket.WithdrawReason,
+		UpdatedAt:      ticket.UpdatedAt,
+	}
+}
+
+// GetDuplicateReport 列出当前批次中疑似重复的申请，供工作人员人工合并。
+// 学号相同、联系方式相同，或姓名与班级都相同的已提交申请会被归为一组。
+//
+// ctx 是上下文。
+func GetDuplicateReport(ctx context.Context) *GetDuplicateReportResponse {
+	campaign := GetCampaign()
+	slog.Debug("model.GetDuplicateReport: 正在生成疑似重复申请报告", "campaign", campaign)
+	srv := service.GetService()
+	var tickets []Ticket
+	err := srv.DB.WithContext(ctx).Model(&Ticket{}).Where(&Ticket{
+		Submitted: &[]bool{true}[0],
+		Campaign:  campaign,
+	}).Order("id").Find(&tickets).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	// 早期版本可能为同一用户创建了多行申请表，每个用户只保留最早的一行，避免用户与自己被归为一组
+	seen := make(map[string]bool)
+	applicants := tickets[:0]
+	for _, v := range tickets {
+		if seen[v.OpenId] {
+			continue
+		}
+		seen[v.OpenId] = true
+		applicants = append(applicants, v)
+	}
+	tickets = applicants
+	preferences := getTicketPreferenceMap(ctx, nil)
+	keys := map[string]func(ticket *Ticket) string{
+		DuplicateReasonStudentId: func(ticket *Ticket) string { return ticket.StudentId },
+		DuplicateReasonContact:   func(ticket *Ticket) string { return strings.ToLower(ticket.Contact) },
+		DuplicateReasonNameClass: func(ticket *Ticket) string {
+			if ticket.Name == "" || ticket.ClassName == "" {
+				return ""
+			}
+			return ticket.Name + "/" + ticket.ClassName
+		},
+	}
+	result := GetDuplicateReportResponse{
+		Campaign:   campaign,
+		Duplicates: make([]DuplicateGroup, 0),
+	}
+	for _, reason := range []string{DuplicateReasonStudentId, DuplicateReasonContact, DuplicateReasonNameClass} {
+		buckets := make(map[string][]TicketAdminItem)
+		for i := range tickets {
+			value := keys[reason](&tickets[i])
+			if value == "" {
+				continue
+			}
+			buckets[value] = append(buckets[value], newTicketAdminItem(&tickets[i], preferences))
+		}
+		values := make([]string, 0, len(buckets))
+		for value, items := range buckets {
+			if len(items) > 1 {
+				values = append(values, value)
+			}
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			result.Duplicates = append(result.Duplicates, DuplicateGroup{
+				Reason:  reason,
+				Value:   value,
+				Tickets: buckets[value],
+			})
+		}
+	}
+	return &result
+}
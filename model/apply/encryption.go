@@ -0,0 +1,167 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/crypto"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// rotationBatchSize 是检查是否需要重新加密时每批读取的记录数。
+const rotationBatchSize = 500
+
+// encryptedRow 是加密列的原始值，读取时不经过serializer解密，用于判断是否需要重新加密。
+type encryptedRow interface {
+	// key 返回记录的主键。
+	key() uint
+	// needsRotation 检查是否有加密列需要重新加密。
+	needsRotation() bool
+}
+
+type encryptedTicket struct {
+	ID        uint
+	Name      string
+	StudentId string
+	Contact   string
+}
+
+func (encryptedTicket) TableName() string {
+	return "tickets"
+}
+
+func (r encryptedTicket) key() uint {
+	return r.ID
+}
+
+func (r encryptedTicket) needsRotation() bool {
+	return crypto.NeedsRotation(r.Name) || crypto.NeedsRotation(r.StudentId) || crypto.NeedsRotation(r.Contact)
+}
+
+type encryptedRevision struct {
+	ID    uint
+	Value string
+}
+
+func (encryptedRevision) TableName() string {
+	return "revisions"
+}
+
+func (r encryptedRevision) key() uint {
+	return r.ID
+}
+
+func (r encryptedRevision) needsRotation() bool {
+	return crypto.NeedsRotation(r.Value)
+}
+
+type encryptedStaffNotice struct {
+	ID      uint
+	Message string
+}
+
+func (encryptedStaffNotice) TableName() string {
+	return "staff_notices"
+}
+
+func (r encryptedStaffNotice) key() uint {
+	return r.ID
+}
+
+func (r encryptedStaffNotice) needsRotation() bool {
+	return crypto.NeedsRotation(r.Message)
+}
+
+// rotateInBatches 分批读取加密列的原始值，只对需要重新加密的记录调用rotate，并返回重新加密的记录数。
+//
+// db 是数据库连接。
+// rotate 重新加密一批记录，参数为记录的主键。
+func rotateInBatches[T encryptedRow](db *gorm.DB, rotate func(ids []uint) error) (int, error) {
+	var rows []T
+	count := 0
+	err := db.FindInBatches(&rows, rotationBatchSize, func(_ *gorm.DB, _ int) error {
+		var ids []uint
+		for _, v := range rows {
+			if v.needsRotation() {
+				ids = append(ids, v.key())
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		count += len(ids)
+		return rotate(ids)
+	}).Error
+	return count, err
+}
+
+// RotateEncryptedFields 用当前版本的密钥重新加密旧的密文和加密启用前写入的明文，并补全盲索引。
+// 读取旧密文不依赖该函数，因此可以在服务运行时执行，重复执行不会有副作用。
+// 数据按批读取，只有需要重新加密的记录会被完整加载，由后台任务执行，见job.newEncryptionJob。
+//
+// ctx 是上下文。
+func RotateEncryptedFields(ctx context.Context) {
+	slog.Debug("model.RotateEncryptedFields: 正在检查需要重新加密的数据")
+	srv := service.GetService()
+	db := srv.DB.WithContext(ctx)
+	columns := []string{"name", "student_id", "contact"}
+	tickets, err := rotateInBatches[encryptedTicket](db, func(ids []uint) error {
+		var tickets []Ticket
+		err := db.Unscoped().Find(&tickets, ids).Error
+		if err != nil {
+			return err
+		}
+		for i := range tickets {
+			tickets[i].StudentIdIndex = crypto.BlindIndex(tickets[i].StudentId)
+			err := db.Unscoped().Model(&tickets[i]).Select(append(columns, "student_id_index")).
+				UpdateColumns(&tickets[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	revisions, err := rotateInBatches[encryptedRevision](db, func(ids []uint) error {
+		var revisions []Revision
+		err := db.Unscoped().Find(&revisions, ids).Error
+		if err != nil {
+			return err
+		}
+		for i := range revisions {
+			err := db.Unscoped().Model(&revisions[i]).Select("value").UpdateColumns(&revisions[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	notices, err := rotateInBatches[encryptedStaffNotice](db, func(ids []uint) error {
+		var notices []StaffNotice
+		err := db.Unscoped().Find(&notices, ids).Error
+		if err != nil {
+			return err
+		}
+		for i := range notices {
+			err := db.Unscoped().Model(&notices[i]).Select("message").UpdateColumns(&notices[i]).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	if tickets > 0 || revisions > 0 || notices > 0 {
+		slog.Info("model.RotateEncryptedFields: 已重新加密数据", "tickets", tickets, "revisions", revisions, "notices", notices)
+	}
+}
@@ -0,0 +1,273 @@
+package apply
+
+import (
+	"archive/zip"
+	"context"
+	"elab-backend/service"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"path"
+	"time"
+)
+
+// PersonalDataExport 是导出给用户本人的全部个人数据。
+// 涵盖的数据与删除账号时清除的数据一致。
+type PersonalDataExport struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// ExportedAt 是导出的时间。
+	ExportedAt time.Time `json:"exported_at"`
+	// Ticket 是用户的申请表，未创建时为nil。
+	Ticket *ExportTicket `json:"ticket"`
+	// Answers 是用户对各问题的回答，包括已移除问题的回答。
+	Answers []ExportAnswer `json:"answers"`
+	// Selection 是用户选择的面试房间，未选择时为nil。
+	Selection *ExportSelection `json:"selection"`
+	// History 是申请表与回答的修改记录，按时间顺序排列。
+	History []RevisionListItem `json:"history"`
+	// Attachments 是用户上传的附件。
+	Attachments []ExportAttachment `json:"attachments"`
+}
+
+// ExportTicket 是导出的申请表。
+type ExportTicket struct {
+	TicketBody
+	// Campaign 是申请表所属的招新批次。
+	Campaign string `json:"campaign"`
+	// Submitted 是申请表是否已提交。
+	Submitted bool `json:"submitted"`
+	// OfferGroup 是录取的组别ID。
+	OfferGroup string `json:"offer_group"`
+	// OfferedAt 是录取的时间。
+	OfferedAt *time.Time `json:"offered_at"`
+	// Withdrawn 是申请是否已撤回。
+	Withdrawn bool `json:"withdrawn"`
+	// WithdrawnAt 是最近一次撤回的时间。
+	WithdrawnAt *time.Time `json:"withdrawn_at"`
+	// WithdrawReason 是最近一次撤回时填写的原因。
+	WithdrawReason string `json:"withdraw_reason"`
+	// CreatedAt 是申请表的创建时间。
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt 是申请表最后一次更新的时间。
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportAnswer 是导出的问题回答。
+type ExportAnswer struct {
+	// QuestionId 是问题ID。
+	QuestionId string `json:"question_id"`
+	// Question 是问题标题，问题已被删除时为空。
+	Question string `json:"question"`
+	// Text 是问题的文字描述。
+	Text string `json:"text"`
+	// Answer 是用户的回答。
+	Answer string `json:"answer"`
+	// Retired 是问题是否已不再展示给用户。
+	Retired bool `json:"retired"`
+	// UpdatedAt 是回答最后一次更新的时间。
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportSelection 是导出的面试房间选择。
+type ExportSelection struct {
+	// RoomId 是房间的唯一标识符。
+	RoomId string `json:"room_id"`
+	// Name 是房间的名称。
+	Name string `json:"name"`
+	// Time 是面试开始时间。
+	Time *time.Time `json:"time"`
+	// EndTime 是面试结束时间。
+	EndTime *time.Time `json:"end_time"`
+	// Location 是房间地点。
+	Location string `json:"location"`
+	// SelectedAt 是选择房间的时间。
+	SelectedAt time.Time `json:"selected_at"`
+}
+
+// ExportAttachment 是导出的附件信息。
+type ExportAttachment struct {
+	// Id 是附件的唯一标识符。
+	Id string `json:"id"`
+	// QuestionId 是附件关联的问题ID。
+	QuestionId string `json:"question_id"`
+	// FileName 是上传时的文件名。
+	FileName string `json:"file_name"`
+	// ContentType 是文件的MIME类型。
+	ContentType string `json:"content_type"`
+	// Size 是文件大小，单位为字节。
+	Size int64 `json:"size"`
+	// CreatedAt 是上传时间。
+	CreatedAt time.Time `json:"created_at"`
+	// Path 是文件在ZIP压缩包中的路径。
+	Path string `json:"path"`
+
+	storageKey string
+}
+
+// GetPersonalData 汇总用户的全部个人数据。
+// 该函数只读取数据，不会像GetTicket等函数一样初始化缺失的记录。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func GetPersonalData(ctx context.Context, openid string) *PersonalDataExport {
+	slog.Debug("model.GetPersonalData: 正在汇总个人数据", "openid", openid)
+	srv := service.GetService()
+	db := srv.DB.WithContext(ctx)
+	result := PersonalDataExport{
+		OpenId:      openid,
+		ExportedAt:  time.Now(),
+		Answers:     make([]ExportAnswer, 0),
+		History:     make([]RevisionListItem, 0),
+		Attachments: make([]ExportAttachment, 0),
+	}
+	if ticket := findTicket(ctx, openid); ticket != nil {
+		result.Ticket = &ExportTicket{
+			TicketBody: TicketBody{
+				Name:        ticket.Name,
+				StudentId:   ticket.StudentId,
+				ClassName:   ticket.ClassName,
+				Group:       ticket.Group,
+				Contact:     ticket.Contact,
+				Preferences: GetTicketGroups(ctx, openid),
+			},
+			Campaign:       ticket.Campaign,
+			Submitted:      ticket.Submitted != nil && *ticket.Submitted,
+			OfferGroup:     ticket.OfferGroup,
+			OfferedAt:      ticket.OfferedAt,
+			Withdrawn:      ticket.Withdrawn != nil && *ticket.Withdrawn,
+			WithdrawnAt:    ticket.WithdrawnAt,
+			WithdrawReason: ticket.WithdrawReason,
+			CreatedAt:      ticket.CreatedAt,
+			UpdatedAt:      ticket.UpdatedAt,
+		}
+	}
+	var answers []TextForm
+	err := db.Model(&TextForm{}).Where(&TextForm{OpenId: openid}).Order("id").Find(&answers).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	var questions []Question
+	err = db.Model(&Question{}).Find(&questions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	questionMap := make(map[string]*Question, len(questions))
+	for i := range questions {
+		questionMap[questions[i].QuestionId] = &questions[i]
+	}
+	for _, v := range answers {
+		item := ExportAnswer{
+			QuestionId: v.QuestionId,
+			Answer:     v.Answer,
+			Retired:    v.Retired != nil && *v.Retired,
+			UpdatedAt:  v.UpdatedAt,
+		}
+		if question, ok := questionMap[v.QuestionId]; ok {
+			item.Question = question.Question
+			item.Text = question.Text
+		}
+		result.Answers = append(result.Answers, item)
+	}
+	var selection Selection
+	err = db.Model(&Selection{}).Where(&Selection{OpenId: openid}).First(&selection).Error
+	if err == nil {
+		result.Selection = &ExportSelection{
+			RoomId:     selection.RoomId,
+			SelectedAt: selection.CreatedAt,
+		}
+		var room Room
+		err = db.Model(&Room{}).Where(&Room{RoomId: selection.RoomId}).First(&room).Error
+		if err == nil {
+			result.Selection.Name = room.Name
+			result.Selection.Time = room.Time
+			result.Selection.EndTime = room.GetEnd()
+			result.Selection.Location = room.Location
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("调用ORM失败。", "error", err)
+			panic(err)
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	var revisions []Revision
+	err = db.Model(&Revision{}).Where(&Revision{OpenId: openid}).Order("id").Find(&revisions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	for _, v := range revisions {
+		result.History = append(result.History, RevisionListItem{
+			Id:        v.ID,
+			Type:      v.Type,
+			TargetId:  v.TargetId,
+			Value:     v.Value,
+			RequestId: v.RequestId,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	for _, v := range findAttachmentList(ctx, openid, "") {
+		result.Attachments = append(result.Attachments, ExportAttachment{
+			Id:          v.AttachmentId,
+			QuestionId:  v.QuestionId,
+			FileName:    v.FileName,
+			ContentType: v.ContentType,
+			Size:        v.Size,
+			CreatedAt:   v.CreatedAt,
+			// 以附件ID作为目录，避免文件名重复或包含路径
+			Path:       path.Join("attachments", v.AttachmentId, path.Base("/"+v.FileName)),
+			storageKey: v.StorageKey,
+		})
+	}
+	return &result
+}
+
+// WritePersonalDataZip 将个人数据写入ZIP压缩包，包括data.json与全部附件。
+//
+// ctx 是上下文。
+// data 是GetPersonalData汇总的个人数据。
+// writer 是压缩包的输出。
+func WritePersonalDataZip(ctx context.Context, data *PersonalDataExport, writer io.Writer) error {
+	slog.Debug("model.WritePersonalDataZip: 正在打包个人数据", "openid", data.OpenId)
+	srv := service.GetService()
+	archive := zip.NewWriter(writer)
+	file, err := archive.Create("data.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(data)
+	if err != nil {
+		return err
+	}
+	for _, v := range data.Attachments {
+		err := func() error {
+			reader, err := srv.Storage.Get(ctx, v.storageKey)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+			file, err := archive.CreateHeader(&zip.FileHeader{
+				Name:     v.Path,
+				Method:   zip.Deflate,
+				Modified: v.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, reader)
+			return err
+		}()
+		if err != nil {
+			slog.Error("model.WritePersonalDataZip: 无法写入附件", "error", err, "attachmentId", v.Id)
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,341 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+const (
+	// RestrictionTypeQuestion 是对问题的组别限制。
+	RestrictionTypeQuestion = "question"
+	// RestrictionTypeRoom 是对房间的组别限制。
+	RestrictionTypeRoom = "room"
+)
+
+// Group 是科中的组别，如“软件组”、“硬件组”等。
+type Group struct {
+	gorm.Model
+	// GroupId 是组别的唯一标识符。
+	GroupId string `gorm:"type:varchar(36);uniqueIndex"`
+	// Name 是组别的名称。
+	Name string `gorm:"type:varchar(255)"`
+	// Description 是组别的介绍。
+	Description string `gorm:"type:text"`
+	// Sequence 是组别的顺序，数值越小越靠前。
+	Sequence int `gorm:"type:int;default:0"`
+}
+
+// GroupRestriction 将问题或房间限制为仅对特定组别开放。
+// 没有任何限制记录的问题或房间对所有组别开放。
+type GroupRestriction struct {
+	gorm.Model
+	// TargetType 是被限制对象的类型，为“question”或“room”。
+	TargetType string `gorm:"type:varchar(16);index:idx_group_restriction_target"`
+	// TargetId 是被限制对象的唯一标识符。
+	TargetId string `gorm:"type:varchar(36);index:idx_group_restriction_target"`
+	// GroupId 是允许的组别的唯一标识符。
+	GroupId string `gorm:"type:varchar(36)"`
+}
+
+// GroupBody 是管理员创建或更新组别的请求。
+type GroupBody struct {
+	// Id 是组别的唯一标识符，创建时为空则自动生成。
+	Id string `json:"id" binding:"omitempty,max=36"`
+	// Name 是组别的名称。
+	Name string `json:"name" binding:"required,max=255"`
+	// Description 是组别的介绍。
+	Description string `json:"description"`
+	// Sequence 是组别的顺序。
+	Sequence int `json:"sequence"`
+}
+
+type GroupRequestUri struct {
+	// Id 是组别的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+}
+
+// GetGroupListResponse 是获取组别列表的响应。
+type GetGroupListResponse struct {
+	Groups []GroupBody `json:"groups"`
+}
+
+type GroupNotFoundError struct{}
+
+func (e *GroupNotFoundError) Error() string {
+	return "组别不存在"
+}
+
+type DuplicateGroupError struct{}
+
+func (e *DuplicateGroupError) Error() string {
+	return "组别ID已存在"
+}
+
+// GetGroupList 获取按顺序排列的组别列表。
+//
+// ctx 是上下文。
+func GetGroupList(ctx context.Context) *GetGroupListResponse {
+	slog.Debug("model.GetGroupList: 正在获取组别列表")
+	srv := service.GetService()
+	var groups []Group
+	err := srv.DB.WithContext(ctx).Model(&Group{}).Order("sequence, id").Find(&groups).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetGroupListResponse{Groups: make([]GroupBody, 0, len(groups))}
+	for _, v := range groups {
+		result.Groups = append(result.Groups, GroupBody{
+			Id:          v.GroupId,
+			Name:        v.Name,
+			Description: v.Description,
+			Sequence:    v.Sequence,
+		})
+	}
+	return &result
+}
+
+// CheckIsGroupExists 检查组别是否存在。
+//
+// ctx 是上下文。
+// groupId 是组别的唯一标识符。
+func CheckIsGroupExists(ctx context.Context, groupId string) bool {
+	slog.Debug("model.CheckIsGroupExists: 正在检查组别是否存在", "groupId", groupId)
+	srv := service.GetService()
+	var group Group
+	err := srv.DB.WithContext(ctx).Model(&Group{}).Where(&Group{GroupId: groupId}).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return true
+}
+
+// CheckIsGroupListExists 检查列表中的组别是否都存在。
+//
+// ctx 是上下文。
+// groups 是组别的唯一标识符列表。
+func CheckIsGroupListExists(ctx context.Context, groups []string) bool {
+	for _, v := range groups {
+		if !CheckIsGroupExists(ctx, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateGroup 创建组别，并返回组别的唯一标识符。
+// 指定的唯一标识符已被使用（包括已删除的组别）时返回*DuplicateGroupError。
+//
+// ctx 是上下文。
+// body 是组别内容。
+func CreateGroup(ctx context.Context, body *GroupBody) (string, error) {
+	slog.Debug("model.CreateGroup: 正在创建组别", "name", body.Name)
+	srv := service.GetService()
+	groupId := body.Id
+	if groupId == "" {
+		groupId = uuid.NewString()
+	}
+	group := Group{
+		GroupId:     groupId,
+		Name:        body.Name,
+		Description: body.Description,
+		Sequence:    body.Sequence,
+	}
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Unscoped().Model(&Group{}).Where(&Group{GroupId: groupId}).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return &DuplicateGroupError{}
+		}
+		err = tx.Create(&group).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "group.create", AuditTargetGroup, groupId, nil, &group)
+	})
+	if err != nil {
+		if v, ok := err.(*DuplicateGroupError); ok {
+			return "", v
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return groupId, nil
+}
+
+// UpdateGroup 更新组别。
+//
+// ctx 是上下文。
+// groupId 是组别的唯一标识符。
+// body 是组别内容。
+func UpdateGroup(ctx context.Context, groupId string, body *GroupBody) error {
+	slog.Debug("model.UpdateGroup: 正在更新组别", "groupId", groupId)
+	before := findAuditSnapshot(ctx, &Group{}, &Group{GroupId: groupId})
+	if before == nil {
+		return &GroupNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		after := *before
+		after.Name = body.Name
+		after.Description = body.Description
+		after.Sequence = body.Sequence
+		err := tx.Model(&Group{}).Where(&Group{GroupId: groupId}).
+			Select("name", "description", "sequence").Updates(&after).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "group.update", AuditTargetGroup, groupId, before, &after)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// DeleteGroup 删除组别，并移除所有指向该组别的限制。
+//
+// ctx 是上下文。
+// groupId 是组别的唯一标识符。
+func DeleteGroup(ctx context.Context, groupId string) error {
+	slog.Debug("model.DeleteGroup: 正在删除组别", "groupId", groupId)
+	before := findAuditSnapshot(ctx, &Group{}, &Group{GroupId: groupId})
+	if before == nil {
+		return &GroupNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&Group{GroupId: groupId}).Delete(&Group{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Unscoped().Where(&GroupRestriction{GroupId: groupId}).Delete(&GroupRestriction{}).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "group.delete", AuditTargetGroup, groupId, before, nil)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// GetGroupRestrictionMap 获取某类对象的组别限制，键为对象的唯一标识符。
+//
+// ctx 是上下文。
+// targetType 是被限制对象的类型。
+func GetGroupRestrictionMap(ctx context.Context, targetType string) map[string][]string {
+	slog.Debug("model.GetGroupRestrictionMap: 正在获取组别限制", "targetType", targetType)
+	srv := service.GetService()
+	var restrictions []GroupRestriction
+	err := srv.DB.WithContext(ctx).Model(&GroupRestriction{}).Where(&GroupRestriction{
+		TargetType: targetType,
+	}).Find(&restrictions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := make(map[string][]string)
+	for _, v := range restrictions {
+		result[v.TargetId] = append(result[v.TargetId], v.GroupId)
+	}
+	return result
+}
+
+// GetGroupRestriction 获取单个对象的组别限制。
+//
+// ctx 是上下文。
+// targetType 是被限制对象的类型。
+// targetId 是被限制对象的唯一标识符。
+func GetGroupRestriction(ctx context.Context, targetType string, targetId string) []string {
+	srv := service.GetService()
+	groups := make([]string, 0)
+	err := srv.DB.WithContext(ctx).Model(&GroupRestriction{}).Where(&GroupRestriction{
+		TargetType: targetType,
+		TargetId:   targetId,
+	}).Pluck("group_id", &groups).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return groups
+}
+
+// SetGroupRestriction 在事务中替换对象的组别限制，groups为空表示对所有组别开放。
+// 调用前应使用CheckIsGroupListExists检查组别是否都存在。
+//
+// tx 是当前事务。
+// targetType 是被限制对象的类型。
+// targetId 是被限制对象的唯一标识符。
+// groups 是允许的组别列表。
+func SetGroupRestriction(ctx context.Context, tx *gorm.DB, targetType string, targetId string, groups []string) error {
+	slog.Debug("model.SetGroupRestriction: 正在设置组别限制", "targetType", targetType, "targetId", targetId, "groups", groups)
+	err := tx.WithContext(ctx).Unscoped().Where(&GroupRestriction{
+		TargetType: targetType,
+		TargetId:   targetId,
+	}).Delete(&GroupRestriction{}).Error
+	if err != nil {
+		return err
+	}
+	for _, v := range groups {
+		err := tx.WithContext(ctx).Create(&GroupRestriction{
+			TargetType: targetType,
+			TargetId:   targetId,
+			GroupId:    v,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isAllowedForGroups 检查用户的志愿组别是否满足限制，任一志愿满足即可。没有限制时对所有人开放。
+//
+// restrictions 是对象的组别限制。
+// groups 是用户的志愿组别。
+func isAllowedForGroups(restrictions []string, groups []string) bool {
+	if len(restrictions) == 0 {
+		return true
+	}
+	for _, v := range restrictions {
+		for _, group := range groups {
+			if v == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowedForGroups 返回筛选对指定组别开放的对象的查询条件，与isAllowedForGroups的判断一致。
+//
+// targetType 是被限制对象的类型。
+// column 是被限制对象的唯一标识符所在的列。
+// groups 是组别列表。
+func allowedForGroups(targetType string, column string, groups []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		restricted := db.Session(&gorm.Session{NewDB: true}).Model(&GroupRestriction{}).Select("target_id").
+			Where("target_type = ?", targetType)
+		if len(groups) == 0 {
+			return db.Where(column+" NOT IN (?)", restricted)
+		}
+		allowed := db.Session(&gorm.Session{NewDB: true}).Model(&GroupRestriction{}).Select("target_id").
+			Where("target_type = ? AND group_id IN ?", targetType, groups)
+		return db.Where("("+column+" NOT IN (?) OR "+column+" IN (?))", restricted, allowed)
+	}
+}
@@ -0,0 +1,264 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+// SeatHold 是用户在确认选择前对房间座位的临时保留，保留期间计入房间的Held。
+// 每个用户同时只能保留一个座位，过期的保留由定时任务清除并归还座位。
+type SeatHold struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);uniqueIndex"`
+	// RoomId 是房间的唯一标识符。
+	RoomId string `gorm:"type:varchar(36);index"`
+	// ExpiresAt 是保留的过期时间。
+	ExpiresAt time.Time `gorm:"type:datetime;index"`
+}
+
+// SeatHoldResponse 是用户查看的座位保留。
+type SeatHoldResponse struct {
+	// RoomId 是房间的唯一标识符。
+	RoomId string `json:"room_id"`
+	// ExpiresAt 是保留的过期时间，为带时区的RFC3339格式。
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SeatHoldNotFoundError struct{}
+
+func (e *SeatHoldNotFoundError) Error() string {
+	return "座位保留不存在或已过期"
+}
+
+// GetSeatHoldDuration 获取座位保留的有效期，由SEAT_HOLD_DURATION指定，默认为5分钟。
+func GetSeatHoldDuration() time.Duration {
+	return config.GetDuration("SEAT_HOLD_DURATION", 5*time.Minute)
+}
+
+// newSeatHoldResponse 根据座位保留创建响应。
+func newSeatHoldResponse(hold *SeatHold) *SeatHoldResponse {
+	return &SeatHoldResponse{
+		RoomId:    hold.RoomId,
+		ExpiresAt: hold.ExpiresAt.In(GetCampaignLocation()),
+	}
+}
+
+// findSeatHold 获取用户未过期的座位保留，不存在时返回nil。
+func findSeatHold(ctx context.Context, openid string) *SeatHold {
+	srv := service.GetService()
+	var hold SeatHold
+	err := srv.DB.WithContext(ctx).Model(&SeatHold{}).Where(&SeatHold{OpenId: openid}).
+		Where("expires_at > ?", time.Now()).First(&hold).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &hold
+}
+
+// hasSeatHold 检查用户是否保留了房间中的座位，已过期但尚未清除的保留也计入，因为它仍占用房间的Held。
+func hasSeatHold(ctx context.Context, openid string, roomId string) bool {
+	srv := service.GetService()
+	var count int64
+	err := srv.DB.WithContext(ctx).Model(&SeatHold{}).Where(&SeatHold{OpenId: openid, RoomId: roomId}).Count(&count).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return count > 0
+}
+
+// GetSeatHold 获取用户当前的座位保留。
+// 没有保留或保留已过期时返回*SeatHoldNotFoundError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func GetSeatHold(ctx context.Context, openid string) (*SeatHoldResponse, error) {
+	slog.Debug("model.GetSeatHold: 正在获取座位保留", "openid", openid)
+	hold := findSeatHold(ctx, openid)
+	if hold == nil {
+		return nil, &SeatHoldNotFoundError{}
+	}
+	return newSeatHoldResponse(hold), nil
+}
+
+// PlaceSeatHold 为用户保留房间中的一个座位，保留期间其他用户无法占用该座位。
+// 用户之前的保留会被替换，已选择的房间在确认之前保持不变。调用方需要持有房间选择的锁。
+// 房间不存在时返回*RoomNotFoundError，房间不面向用户的组别时返回*RoomGroupMismatchError，
+// 已经选择了该房间时返回*DuplicateSelectionError，房间已满时返回*RoomFullError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// roomId 是房间的唯一标识符。
+func PlaceSeatHold(ctx context.Context, openid string, roomId string) (*SeatHoldResponse, error) {
+	slog.Debug("model.PlaceSeatHold: 正在保留座位", "openid", openid, "roomId", roomId)
+	srv := service.GetService()
+	if !CheckIsRoomExists(ctx, roomId) {
+		return nil, &RoomNotFoundError{}
+	}
+	if !isAllowedForGroups(GetGroupRestriction(ctx, RestrictionTypeRoom, roomId), GetTicketGroups(ctx, openid)) {
+		return nil, &RoomGroupMismatchError{}
+	}
+	if selectedRoomId, ok := CheckIsAlreadySelected(ctx, openid); ok && selectedRoomId == roomId {
+		return nil, &DuplicateSelectionError{}
+	}
+	room := findRoom(ctx, roomId)
+	if room == nil {
+		return nil, &RoomNotFoundError{}
+	}
+	// 重新保留同一房间时只延长有效期，不会因为自己的保留而认为房间已满
+	held := room.Held
+	if hasSeatHold(ctx, openid, roomId) {
+		held--
+	}
+	if room.Occupancy+held >= room.Capacity {
+		slog.Debug("model.PlaceSeatHold: 房间已满", "roomId", roomId)
+		return nil, &RoomFullError{}
+	}
+	hold := SeatHold{
+		OpenId:    openid,
+		RoomId:    roomId,
+		ExpiresAt: time.Now().Add(GetSeatHoldDuration()),
+	}
+	var releasedRoomId string
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		releasedRoomId, err = releaseSeatHold(tx, openid)
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&hold).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Room{}).Where(&Room{RoomId: roomId}).Update("held", gorm.Expr("held + 1")).Error
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	publishRoomOccupancy(ctx, roomId, releasedRoomId)
+	return newSeatHoldResponse(&hold), nil
+}
+
+// ReleaseSeatHold 取消用户的座位保留，座位立即归还给房间。调用方需要持有房间选择的锁。
+// 没有保留或保留已过期时返回*SeatHoldNotFoundError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func ReleaseSeatHold(ctx context.Context, openid string) error {
+	slog.Debug("model.ReleaseSeatHold: 正在取消座位保留", "openid", openid)
+	if findSeatHold(ctx, openid) == nil {
+		return &SeatHoldNotFoundError{}
+	}
+	srv := service.GetService()
+	var roomId string
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		roomId, err = releaseSeatHold(tx, openid)
+		return err
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	publishRoomOccupancy(ctx, roomId)
+	return nil
+}
+
+// ConfirmSeatHold 将用户的座位保留确认为房间选择，用户之前的选择会被替换。调用方需要持有房间选择的锁。
+// 没有保留或保留已过期时返回*SeatHoldNotFoundError，
+// 保留期间管理员停用了房间时返回*RoomNotFoundError，减少了容量导致已满时返回*RoomFullError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func ConfirmSeatHold(ctx context.Context, openid string) error {
+	slog.Debug("model.ConfirmSeatHold: 正在确认座位保留", "openid", openid)
+	hold := findSeatHold(ctx, openid)
+	if hold == nil {
+		return &SeatHoldNotFoundError{}
+	}
+	if !CheckIsRoomExists(ctx, hold.RoomId) {
+		return &RoomNotFoundError{}
+	}
+	// 用户自己的保留计入Held，不应占用自己的座位
+	room := findRoom(ctx, hold.RoomId)
+	if room.Occupancy+room.Held-1 >= room.Capacity {
+		return &RoomFullError{}
+	}
+	selectedRoomId, _ := CheckIsAlreadySelected(ctx, openid)
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := releaseSeatHold(tx, openid)
+		if err != nil {
+			return err
+		}
+		return applySelection(ctx, tx, openid, hold.RoomId, selectedRoomId)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	publishRoomOccupancy(ctx, hold.RoomId, selectedRoomId)
+	return nil
+}
+
+// ReleaseExpiredSeatHolds 清除过期的座位保留并归还座位，返回清除的数量。调用方需要持有房间选择的锁。
+//
+// ctx 是上下文。
+func ReleaseExpiredSeatHolds(ctx context.Context) int {
+	srv := service.GetService()
+	var holds []SeatHold
+	err := srv.DB.WithContext(ctx).Model(&SeatHold{}).Where("expires_at <= ?", time.Now()).Find(&holds).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	var roomIds []string
+	for _, v := range holds {
+		err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return deleteSeatHold(tx, &v)
+		})
+		if err != nil {
+			slog.Error("调用ORM失败。", "error", err)
+			panic(err)
+		}
+		roomIds = append(roomIds, v.RoomId)
+	}
+	publishRoomOccupancy(ctx, roomIds...)
+	return len(holds)
+}
+
+// releaseSeatHold 在事务中删除用户的座位保留（包括已过期但尚未清除的），并返回被归还座位的房间，没有保留时返回空字符串。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+func releaseSeatHold(tx *gorm.DB, openid string) (string, error) {
+	var holds []SeatHold
+	err := tx.Model(&SeatHold{}).Where(&SeatHold{OpenId: openid}).Find(&holds).Error
+	if err != nil || len(holds) == 0 {
+		return "", err
+	}
+	return holds[0].RoomId, deleteSeatHold(tx, &holds[0])
+}
+
+// deleteSeatHold 在事务中删除一条座位保留，并减少房间的Held。
+// 只有确实删除了记录时才会减少，重复删除不会多归还座位。
+func deleteSeatHold(tx *gorm.DB, hold *SeatHold) error {
+	result := tx.Where("id = ?", hold.ID).Delete(&SeatHold{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return tx.Model(&Room{}).Where(&Room{RoomId: hold.RoomId}).Where("held > 0").
+		Update("held", gorm.Expr("held - 1")).Error
+}
@@ -0,0 +1,134 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"gorm.io/gorm"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// NoticeTypeWithdraw 是申请人撤回申请的通知。
+	NoticeTypeWithdraw = "withdraw"
+)
+
+// StaffNotice 是发给工作人员的站内通知。
+type StaffNotice struct {
+	gorm.Model
+	// Type 是通知的类型。
+	Type string `gorm:"type:varchar(32)"`
+	// OpenId 是通知涉及的用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// Message 是通知的内容，可能含有申请者的姓名、学号与撤回原因，因此加密存储。
+	Message string `gorm:"type:text;serializer:encrypted"`
+	// Read 是通知是否已读。
+	Read *bool `gorm:"type:bool;index"`
+}
+
+// StaffNoticeListItem 是通知列表项。
+type StaffNoticeListItem struct {
+	// Id 是通知的唯一标识符。
+	Id uint `json:"id"`
+	// Type 是通知的类型。
+	Type string `json:"type"`
+	// OpenId 是通知涉及的用户的OpenId。
+	OpenId string `json:"openid"`
+	// Message 是通知的内容。
+	Message string `json:"message"`
+	// Read 是通知是否已读。
+	Read bool `json:"read"`
+	// CreatedAt 是通知的创建时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetStaffNoticeListResponse 是获取通知列表的响应。
+type GetStaffNoticeListResponse struct {
+	Notices []StaffNoticeListItem `json:"notices"`
+}
+
+// StaffNoticeQuery 是查询通知的条件。
+type StaffNoticeQuery struct {
+	// Unread 为true时只返回未读的通知。
+	Unread bool `form:"unread"`
+}
+
+type StaffNoticeRequestUri struct {
+	// Id 是通知的唯一标识符。
+	Id uint `uri:"id" binding:"required"`
+}
+
+type StaffNoticeNotFoundError struct{}
+
+func (e *StaffNoticeNotFoundError) Error() string {
+	return "通知不存在"
+}
+
+// createStaffNotice 在事务中创建一条工作人员通知。
+//
+// tx 是当前事务。
+// noticeType 是通知的类型。
+// openid 是通知涉及的用户的Openid。
+// message 是通知的内容。
+func createStaffNotice(ctx context.Context, tx *gorm.DB, noticeType string, openid string, message string) error {
+	slog.Debug("model.createStaffNotice: 正在创建工作人员通知", "type", noticeType, "openid", openid)
+	return tx.WithContext(ctx).Create(&StaffNotice{
+		Type:    noticeType,
+		OpenId:  openid,
+		Message: message,
+		Read:    &[]bool{false}[0],
+	}).Error
+}
+
+// GetStaffNoticeList 获取工作人员通知列表，按时间倒序排列。
+//
+// ctx 是上下文。
+// query 是查询条件。
+func GetStaffNoticeList(ctx context.Context, query *StaffNoticeQuery) *GetStaffNoticeListResponse {
+	slog.Debug("model.GetStaffNoticeList: 正在获取工作人员通知", "unread", query.Unread)
+	srv := service.GetService()
+	var notices []StaffNotice
+	db := srv.DB.WithContext(ctx).Model(&StaffNotice{})
+	if query.Unread {
+		db = db.Where(&StaffNotice{Read: &[]bool{false}[0]})
+	}
+	err := db.Order("id DESC").Find(&notices).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetStaffNoticeListResponse{Notices: make([]StaffNoticeListItem, 0, len(notices))}
+	for _, v := range notices {
+		result.Notices = append(result.Notices, StaffNoticeListItem{
+			Id:        v.ID,
+			Type:      v.Type,
+			OpenId:    v.OpenId,
+			Message:   v.Message,
+			Read:      v.Read != nil && *v.Read,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return &result
+}
+
+// MarkStaffNoticeRead 将工作人员通知标记为已读。
+//
+// ctx 是上下文。
+// id 是通知的唯一标识符。
+func MarkStaffNoticeRead(ctx context.Context, id uint) error {
+	slog.Debug("model.MarkStaffNoticeRead: 正在标记通知为已读", "id", id)
+	srv := service.GetService()
+	result := srv.DB.WithContext(ctx).Model(&StaffNotice{}).Where("id = ?", id).Updates(&StaffNotice{
+		Read: &[]bool{true}[0],
+	})
+	if result.Error != nil {
+		slog.Error("调用ORM失败。", "error", result.Error)
+		panic(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return &StaffNoticeNotFoundError{}
+	}
+	recordAuditLog(ctx, "notice.read", AuditTargetNotice, strconv.FormatUint(uint64(id), 10), nil, nil)
+	return nil
+}
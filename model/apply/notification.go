@@ -0,0 +1,115 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/notify"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// DeliverNotification 根据事件向用户发送通知邮件，是事件的消费方之一。
+// 模板数据在投递时读取，其中会自动加入用户的姓名与第一志愿组别。
+// 不需要通知的事件、已删除的用户以及没有邮箱的用户都视为投递成功。
+//
+// ctx 是上下文。
+// event 是要投递的事件。
+func DeliverNotification(ctx context.Context, event *OutboxEvent) error {
+	data := make(map[string]interface{})
+	var notifyEvent string
+	switch event.EventType {
+	case OutboxEventTicketSubmitted:
+		var payload TicketSubmittedPayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		// 只在首次提交或撤回后重新提交时通知，之后的修改不再通知
+		if !payload.First {
+			return nil
+		}
+		notifyEvent = notify.EventTicketSubmitted
+	case OutboxEventSelectionChanged:
+		var payload SelectionChangedPayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		if payload.RoomId == "" {
+			return nil
+		}
+		room := findRoom(ctx, payload.RoomId)
+		if room == nil {
+			slog.Debug("model.DeliverNotification: 房间不存在，不发送通知", "roomId", payload.RoomId)
+			return nil
+		}
+		notifyEvent = notify.EventRoomSelected
+		data["RoomName"] = room.Name
+		if room.Time != nil {
+			data["RoomTime"] = FormatCampaignTime(*room.Time)
+		}
+		data["RoomLocation"] = room.Location
+	case OutboxEventInterviewReminder:
+		var payload InterviewReminderPayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		room := findRoom(ctx, payload.RoomId)
+		selectedRoomId, isSelected := CheckIsAlreadySelected(ctx, event.OpenId)
+		// 用户已更换房间或面试时间已变化时，提醒已经过时
+		if room == nil || room.Time == nil || !room.Time.Equal(payload.RoomTime) || !isSelected || selectedRoomId != payload.RoomId {
+			slog.Debug("model.DeliverNotification: 提醒已过时，不发送通知", "openid", event.OpenId, "roomId", payload.RoomId)
+			return nil
+		}
+		notifyEvent = notify.EventInterviewReminder
+		data["RoomName"] = room.Name
+		data["RoomTime"] = FormatCampaignTime(*room.Time)
+		data["RoomLocation"] = room.Location
+	case OutboxEventStatusChanged:
+		var payload StatusChangedPayload
+		if err := event.Decode(&payload); err != nil {
+			return err
+		}
+		if payload.Status != ApplicationStatusTextFormCompleted {
+			return nil
+		}
+		notifyEvent = notify.EventTextFormCompleted
+	default:
+		return nil
+	}
+	ticket := findTicket(ctx, event.OpenId)
+	if ticket == nil {
+		slog.Debug("model.DeliverNotification: 申请表不存在，不发送通知", "openid", event.OpenId, "event", notifyEvent)
+		return nil
+	}
+	data["Name"] = ticket.Name
+	data["Group"] = ticket.Group
+	for _, v := range GetGroupList(ctx).Groups {
+		if v.Id == ticket.Group {
+			data["Group"] = v.Name
+		}
+	}
+	err := notify.Deliver(ctx, event.OpenId, ticket.Contact, event.Language, notifyEvent, data)
+	if _, ok := err.(*notify.NoRecipientError); ok {
+		slog.Debug("model.DeliverNotification: 用户没有邮箱，不发送通知", "openid", event.OpenId)
+		return nil
+	}
+	return err
+}
+
+// findRoom 获取房间，包括不可用的房间，不存在时返回nil。
+//
+// ctx 是上下文。
+// roomId 是房间的唯一标识符。
+func findRoom(ctx context.Context, roomId string) *Room {
+	srv := service.GetService()
+	var room Room
+	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{RoomId: roomId}).First(&room).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &room
+}
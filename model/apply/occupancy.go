@@ -0,0 +1,168 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// roomOccupancyChannel 是房间占用情况推送频道的前缀，后接招新时区中的日期。
+const roomOccupancyChannel = "room_occupancy:"
+
+// RoomOccupancy 是房间占用情况的推送消息。
+type RoomOccupancy struct {
+	// Id 是房间的唯一标识符。
+	Id string `json:"id"`
+	// Date 是招新时区中的面试日期。
+	Date string `json:"date"`
+	// Capacity 是房间的容量。
+	Capacity int `json:"capacity"`
+	// Occupancy 是房间的占用人数。
+	Occupancy int `json:"occupancy"`
+	// Held 是房间中被临时保留的座位数量。
+	Held int `json:"held"`
+	// FreeSeats 是房间的空位数量。
+	FreeSeats int `json:"free_seats"`
+}
+
+// RoomOccupancySubscription 是用户对某个日期房间占用情况的订阅，只会收到对用户组别开放的房间。
+type RoomOccupancySubscription struct {
+	// Snapshot 是订阅时该日期全部房间的占用情况，订阅之后的变化会从Updates收到。
+	Snapshot []RoomOccupancy
+	pubsub   *redis.PubSub
+	updates  chan RoomOccupancy
+	done     chan struct{}
+	once     sync.Once
+}
+
+// GetOccupancyHeartbeat 获取占用情况推送连接的心跳间隔，由OCCUPANCY_HEARTBEAT指定，默认为15秒。
+func GetOccupancyHeartbeat() time.Duration {
+	return config.GetDuration("OCCUPANCY_HEARTBEAT", 15*time.Second)
+}
+
+// newRoomOccupancy 根据房间创建推送消息。
+func newRoomOccupancy(room *Room) RoomOccupancy {
+	return RoomOccupancy{
+		Id:        room.RoomId,
+		Date:      room.Time.In(GetCampaignLocation()).Format("2006-01-02"),
+		Capacity:  room.Capacity,
+		Occupancy: room.Occupancy,
+		Held:      room.Held,
+		FreeSeats: max(room.Capacity-room.Occupancy-room.Held, 0),
+	}
+}
+
+// publishRoomOccupancy 在事务提交后向房间所在日期的频道推送最新的占用情况，未安排时间或不可选的房间不会推送。
+// 推送只是为了让客户端不必轮询，失败时只记录日志，不影响已经提交的修改。
+//
+// ctx 是上下文。
+// roomIds 是占用情况发生变化的房间。
+func publishRoomOccupancy(ctx context.Context, roomIds ...string) {
+	if len(roomIds) == 0 {
+		return
+	}
+	srv := service.GetService()
+	var rooms []Room
+	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{Available: &[]bool{true}[0]}).
+		Where("room_id IN ? AND time IS NOT NULL", roomIds).Find(&rooms).Error
+	if err != nil {
+		// 修改已经提交，不能因为推送失败而返回错误
+		slog.Error("调用ORM失败。", "error", err)
+		return
+	}
+	for _, room := range rooms {
+		occupancy := newRoomOccupancy(&room)
+		message, err := json.Marshal(&occupancy)
+		if err != nil {
+			slog.Error("model.publishRoomOccupancy: 无法序列化消息", "error", err)
+			continue
+		}
+		err = srv.Redis.Publish(ctx, roomOccupancyChannel+occupancy.Date, message).Err()
+		if err != nil {
+			slog.Warn("model.publishRoomOccupancy: 推送占用情况失败", "roomId", room.RoomId, "error", err)
+		}
+	}
+}
+
+// SubscribeRoomOccupancy 订阅某个日期房间占用情况的变化，使用完毕后需要调用Close。
+// 先订阅频道再读取快照，因此两者之间的变化不会丢失，只可能重复收到。
+// 日期格式错误时返回*InvalidDateError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// date 是招新时区中的日期，格式为“YYYY-MM-DD”。
+func SubscribeRoomOccupancy(ctx context.Context, openid string, date string) (*RoomOccupancySubscription, error) {
+	slog.Debug("model.SubscribeRoomOccupancy: 正在订阅房间占用情况", "openid", openid, "date", date)
+	timeStart, timeEnd, err := ParseCampaignDate(date)
+	if err != nil {
+		return nil, err
+	}
+	srv := service.GetService()
+	pubsub := srv.Redis.Subscribe(ctx, roomOccupancyChannel+timeStart.Format("2006-01-02"))
+	_, err = pubsub.Receive(ctx)
+	if err != nil {
+		_ = pubsub.Close()
+		slog.Error("调用Redis失败。", "error", err)
+		panic(err)
+	}
+	groups := GetTicketGroups(ctx, openid)
+	var rooms []Room
+	err = srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{Available: &[]bool{true}[0]}).
+		Where("time >= ? AND time < ?", timeStart, timeEnd).
+		Scopes(allowedForGroups(RestrictionTypeRoom, "room_id", groups)).Order("time, id").Find(&rooms).Error
+	if err != nil {
+		_ = pubsub.Close()
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	subscription := RoomOccupancySubscription{
+		Snapshot: make([]RoomOccupancy, 0, len(rooms)),
+		pubsub:   pubsub,
+		updates:  make(chan RoomOccupancy, 16),
+		done:     make(chan struct{}),
+	}
+	for _, room := range rooms {
+		subscription.Snapshot = append(subscription.Snapshot, newRoomOccupancy(&room))
+	}
+	go subscription.forward(GetGroupRestrictionMap(ctx, RestrictionTypeRoom), groups)
+	return &subscription, nil
+}
+
+// forward 解析频道中的消息，并将对用户组别开放的房间转发到updates。
+func (s *RoomOccupancySubscription) forward(restrictions map[string][]string, groups []string) {
+	defer close(s.updates)
+	for message := range s.pubsub.Channel() {
+		var occupancy RoomOccupancy
+		err := json.Unmarshal([]byte(message.Payload), &occupancy)
+		if err != nil {
+			slog.Warn("model.RoomOccupancySubscription: 无法解析消息", "error", err)
+			continue
+		}
+		if !isAllowedForGroups(restrictions[occupancy.Id], groups) {
+			continue
+		}
+		select {
+		case s.updates <- occupancy:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Updates 返回占用情况变化的通道，订阅关闭后通道会被关闭。
+func (s *RoomOccupancySubscription) Updates() <-chan RoomOccupancy {
+	return s.updates
+}
+
+// Close 取消订阅，可以重复调用。
+func (s *RoomOccupancySubscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		_ = s.pubsub.Close()
+	})
+}
@@ -0,0 +1,194 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+// TicketQuery 是工作人员筛选申请表的条件。
+type TicketQuery struct {
+	// Group 是志愿中包含的组别ID。
+	Group string `form:"group" binding:"omitempty,max=36"`
+	// Rank 是Group所在的志愿顺位，为0时不限顺位。
+	Rank int `form:"rank" binding:"omitempty,min=1"`
+	// Offered 为true时只返回已录取的申请，为false时只返回未录取的申请。
+	Offered *bool `form:"offered"`
+	// Withdrawn 为true时改为返回已撤回的申请。
+	Withdrawn bool `form:"withdrawn"`
+}
+
+// GetTicketListResponse 是工作人员获取申请表列表的响应。
+type GetTicketListResponse struct {
+	Tickets []TicketAdminItem `json:"tickets"`
+}
+
+type TicketRequestUri struct {
+	// OpenId 是申请人的OpenId。
+	OpenId string `uri:"openid" binding:"required"`
+}
+
+// MakeOfferRequest 是录取申请人的请求。
+type MakeOfferRequest struct {
+	// Group 是录取的组别ID，需要在申请人的志愿中。
+	Group string `json:"group" binding:"required,max=36"`
+}
+
+// offerSnapshot 是审计记录中的录取状态。
+type offerSnapshot struct {
+	OfferGroup string     `json:"offer_group"`
+	OfferedAt  *time.Time `json:"offered_at"`
+}
+
+func newOfferSnapshot(ctx context.Context, openid string) *offerSnapshot {
+	ticket := findTicket(ctx, openid)
+	if ticket == nil {
+		return nil
+	}
+	return &offerSnapshot{OfferGroup: ticket.OfferGroup, OfferedAt: ticket.OfferedAt}
+}
+
+type TicketNotFoundError struct{}
+
+func (e *TicketNotFoundError) Error() string {
+	return "申请表不存在或未提交"
+}
+
+// QueryTicketList 按志愿筛选当前批次中已提交或已撤回的申请表。
+//
+// ctx 是上下文。
+// query 是筛选条件。
+func QueryTicketList(ctx context.Context, query *TicketQuery) *GetTicketListResponse {
+	slog.Debug("model.QueryTicketList: 正在查询申请表", "query", query)
+	srv := service.GetService()
+	var tickets []Ticket
+	db := srv.DB.WithContext(ctx).Model(&Ticket{}).Where(&Ticket{
+		Campaign: GetCampaign(),
+	})
+	if query.Withdrawn {
+		db = db.Where(&Ticket{Withdrawn: &[]bool{true}[0]})
+	} else {
+		db = db.Where(&Ticket{Submitted: &[]bool{true}[0]})
+	}
+	if query.Group != "" {
+		// 兼容尚未记录志愿的旧申请表，以其组别作为第一志愿
+		preferences := srv.DB.Model(&TicketPreference{}).Select("open_id").Where(&TicketPreference{
+			GroupId: query.Group,
+			Rank:    query.Rank,
+		})
+		if query.Rank == 0 || query.Rank == 1 {
+			db = db.Where(
+				srv.DB.Where("open_id IN (?)", preferences).
+					Or("`group` = ? AND open_id NOT IN (?)", query.Group,
+						srv.DB.Model(&TicketPreference{}).Select("open_id")))
+		} else {
+			db = db.Where("open_id IN (?)", preferences)
+		}
+	}
+	if query.Offered != nil {
+		if *query.Offered {
+			db = db.Where("offer_group <> ''")
+		} else {
+			db = db.Where("offer_group = '' OR offer_group IS NULL")
+		}
+	}
+	err := db.Order("id").Find(&tickets).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	openids := make([]string, 0, len(tickets))
+	for _, v := range tickets {
+		openids = append(openids, v.OpenId)
+	}
+	preferences := getTicketPreferenceMap(ctx, openids)
+	result := GetTicketListResponse{Tickets: make([]TicketAdminItem, 0, len(tickets))}
+	for i := range tickets {
+		result.Tickets = append(result.Tickets, newTicketAdminItem(&tickets[i], preferences))
+	}
+	return &result
+}
+
+// MakeOffer 将申请人录取到其志愿中的某个组别，重复录取会覆盖之前的结果。
+//
+// ctx 是上下文。
+// openid 是申请人的Openid。
+// groupId 是录取的组别ID。
+func MakeOffer(ctx context.Context, openid string, groupId string) error {
+	slog.Debug("model.MakeOffer: 正在录取申请人", "openid", openid, "groupId", groupId)
+	if !CheckIsTicketExists(ctx, openid) {
+		return &TicketNotFoundError{}
+	}
+	inPreferences := false
+	for _, v := range GetTicketGroups(ctx, openid) {
+		if v == groupId {
+			inPreferences = true
+		}
+	}
+	if !inPreferences {
+		return &GroupNotInPreferencesError{}
+	}
+	before := newOfferSnapshot(ctx, openid)
+	srv := service.GetService()
+	now := time.Now()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Ticket{}).Where(&Ticket{OpenId: openid}).Updates(&Ticket{
+			OfferGroup: groupId,
+			OfferedAt:  &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = createAuditLog(ctx, tx, "ticket.offer", AuditTargetTicket, openid, before,
+			&offerSnapshot{OfferGroup: groupId, OfferedAt: &now})
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, OutboxEventStatusChanged, openid, &StatusChangedPayload{
+			OpenId: openid,
+			Status: ApplicationStatusOffered,
+			Group:  groupId,
+		})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// WithdrawOffer 撤销申请人的录取结果。
+//
+// ctx 是上下文。
+// openid 是申请人的Openid。
+func WithdrawOffer(ctx context.Context, openid string) error {
+	slog.Debug("model.WithdrawOffer: 正在撤销录取", "openid", openid)
+	if !CheckIsTicketExists(ctx, openid) {
+		return &TicketNotFoundError{}
+	}
+	before := newOfferSnapshot(ctx, openid)
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Ticket{}).Where(&Ticket{OpenId: openid}).
+			Select("offer_group", "offered_at").Updates(&Ticket{}).Error
+		if err != nil {
+			return err
+		}
+		err = createAuditLog(ctx, tx, "ticket.offer.withdraw", AuditTargetTicket, openid, before, &offerSnapshot{})
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, OutboxEventStatusChanged, openid, &StatusChangedPayload{
+			OpenId: openid,
+			Status: ApplicationStatusOfferWithdrawn,
+			Group:  before.OfferGroup,
+		})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
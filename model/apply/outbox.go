@@ -0,0 +1,379 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"elab-backend/util/request"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// OutboxEventTicketSubmitted 是提交或修改申请表的事件。
+	OutboxEventTicketSubmitted = "ticket.submitted"
+	// OutboxEventSelectionChanged 是选择、更改或释放面试房间的事件。
+	OutboxEventSelectionChanged = "selection.changed"
+	// OutboxEventStatusChanged 是申请状态变化的事件，如完成文本表单、撤回申请、录取。
+	OutboxEventStatusChanged = "status.changed"
+	// OutboxEventInterviewReminder 是面试即将开始的提醒事件，见ScheduleInterviewReminders。
+	OutboxEventInterviewReminder = "interview.reminder"
+)
+
+const (
+	// OutboxStatusPending 表示事件等待投递，包括投递失败后等待重试。
+	OutboxStatusPending = "pending"
+	// OutboxStatusDelivered 表示事件已经投递成功。
+	OutboxStatusDelivered = "delivered"
+	// OutboxStatusDead 表示重试次数用尽，需要工作人员处理后手动重试。
+	OutboxStatusDead = "dead"
+)
+
+const (
+	// ApplicationStatusTextFormCompleted 表示用户回答完了文本表单的全部问题。
+	ApplicationStatusTextFormCompleted = "textform_completed"
+	// ApplicationStatusWithdrawn 表示用户撤回了申请。
+	ApplicationStatusWithdrawn = "withdrawn"
+	// ApplicationStatusOffered 表示用户被录取。
+	ApplicationStatusOffered = "offered"
+	// ApplicationStatusOfferWithdrawn 表示用户的录取结果被撤销。
+	ApplicationStatusOfferWithdrawn = "offer_withdrawn"
+)
+
+const (
+	// NoticeTypeOutboxDead 是事件投递失败的通知。
+	NoticeTypeOutboxDead = "outbox_dead"
+)
+
+// OutboxEvent 是等待投递的领域事件。
+// 事件与产生它的修改写在同一个事务中，由后台任务至少投递一次，消费方需要能处理重复的事件。
+// 载荷中只保存标识符，不保存个人信息，投递时再读取最新的数据。
+type OutboxEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	// EventType 是事件的类型。
+	EventType string `gorm:"type:varchar(64);index"`
+	// OpenId 是事件涉及的用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// Payload 是事件的载荷，为JSON。
+	Payload string `gorm:"type:text"`
+	// Language 是产生事件的请求的语言，用于发送通知。
+	Language string `gorm:"type:varchar(8)"`
+	// RequestId 是产生事件的请求的请求ID。
+	RequestId string `gorm:"type:varchar(64)"`
+	// Status 是事件的投递状态。
+	Status string `gorm:"type:varchar(16);index"`
+	// Attempts 是已经尝试投递的次数。
+	Attempts int `gorm:"type:int;default:0"`
+	// NextAttemptAt 是下一次尝试投递的时间。
+	NextAttemptAt time.Time `gorm:"type:datetime;index"`
+	// LastError 是最近一次投递失败的原因。
+	LastError string `gorm:"type:text"`
+	// DeliveredAt 是投递成功的时间。
+	DeliveredAt *time.Time `gorm:"type:datetime"`
+}
+
+// TicketSubmittedPayload 是提交申请表事件的载荷。
+type TicketSubmittedPayload struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// Group 是第一志愿组别ID。
+	Group string `json:"group"`
+	// Preferences 是按顺位排列的志愿组别ID。
+	Preferences []string `json:"preferences"`
+	// First 为true时表示首次提交或撤回后重新提交，否则为修改已提交的申请表。
+	First bool `json:"first"`
+}
+
+// SelectionChangedPayload 是房间选择变化事件的载荷。
+type SelectionChangedPayload struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// RoomId 是当前选择的房间ID，为空表示释放了房间。
+	RoomId string `json:"room_id"`
+	// PreviousRoomId 是之前选择的房间ID，为空表示之前没有选择。
+	PreviousRoomId string `json:"previous_room_id"`
+}
+
+// StatusChangedPayload 是申请状态变化事件的载荷。
+type StatusChangedPayload struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// Status 是新的状态。
+	Status string `json:"status"`
+	// Group 是与状态相关的组别ID，如录取的组别。
+	Group string `json:"group,omitempty"`
+}
+
+// OutboxEventListItem 是事件列表项。
+type OutboxEventListItem struct {
+	// Id 是事件的唯一标识符。
+	Id uint `json:"id"`
+	// EventType 是事件的类型。
+	EventType string `json:"event_type"`
+	// OpenId 是事件涉及的用户的OpenId。
+	OpenId string `json:"openid"`
+	// Payload 是事件的载荷。
+	Payload json.RawMessage `json:"payload"`
+	// RequestId 是产生事件的请求的请求ID。
+	RequestId string `json:"request_id"`
+	// Status 是事件的投递状态。
+	Status string `json:"status"`
+	// Attempts 是已经尝试投递的次数。
+	Attempts int `json:"attempts"`
+	// NextAttemptAt 是下一次尝试投递的时间。
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// LastError 是最近一次投递失败的原因。
+	LastError string `json:"last_error"`
+	// DeliveredAt 是投递成功的时间。
+	DeliveredAt *time.Time `json:"delivered_at"`
+	// CreatedAt 是事件产生的时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetOutboxEventListResponse 是获取事件列表的响应。
+type GetOutboxEventListResponse struct {
+	Events []OutboxEventListItem `json:"events"`
+}
+
+// OutboxEventQuery 是查询事件的条件。
+type OutboxEventQuery struct {
+	// Status 是投递状态。
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	// EventType 是事件的类型。
+	EventType string `form:"event_type"`
+	// OpenId 是事件涉及的用户的OpenId。
+	OpenId string `form:"openid"`
+	// Limit 是返回的最大条数，默认为100。
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+type OutboxEventRequestUri struct {
+	// Id 是事件的唯一标识符。
+	Id uint `uri:"id" binding:"required"`
+}
+
+type OutboxEventNotFoundError struct{}
+
+func (e *OutboxEventNotFoundError) Error() string {
+	return "事件不存在"
+}
+
+type OutboxEventNotDeadError struct{}
+
+func (e *OutboxEventNotDeadError) Error() string {
+	return "只能重试投递失败的事件"
+}
+
+func getOutboxMaxAttempts() int {
+	return int(config.GetInt("OUTBOX_MAX_ATTEMPTS", 8))
+}
+
+// enqueueEvent 在事务中写入一个领域事件，事务提交后才会被投递。
+//
+// tx 是当前事务。
+// eventType 是事件的类型。
+// openid 是事件涉及的用户的Openid。
+// payload 是事件的载荷，会被序列化为JSON。
+func enqueueEvent(ctx context.Context, tx *gorm.DB, eventType string, openid string, payload interface{}) error {
+	slog.Debug("model.enqueueEvent: 正在写入事件", "type", eventType, "openid", openid)
+	value, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.WithContext(ctx).Create(&OutboxEvent{
+		EventType:     eventType,
+		OpenId:        openid,
+		Payload:       string(value),
+		Language:      request.GetLanguage(ctx),
+		RequestId:     request.GetRequestId(ctx),
+		Status:        OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Decode 将事件的载荷解析到dest中。
+func (event *OutboxEvent) Decode(dest interface{}) error {
+	return json.Unmarshal([]byte(event.Payload), dest)
+}
+
+// GetDueOutboxEvents 获取到期需要投递的事件，按产生的顺序排列。
+//
+// ctx 是上下文。
+// limit 是返回的最大条数。
+func GetDueOutboxEvents(ctx context.Context, limit int) []OutboxEvent {
+	srv := service.GetService()
+	var events []OutboxEvent
+	err := srv.DB.WithContext(ctx).Model(&OutboxEvent{}).Where(&OutboxEvent{
+		Status: OutboxStatusPending,
+	}).Where("next_attempt_at <= ?", time.Now()).Order("id").Limit(limit).Find(&events).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return events
+}
+
+// ClaimOutboxEvent 在投递前认领事件，返回false时说明事件已被其他实例认领或处理，不应再投递。
+// 认领会推迟下一次尝试的时间，投递超过OUTBOX_CLAIM_TIMEOUT（默认为1分钟）仍未完成时，事件可以被重新认领。
+//
+// ctx 是上下文。
+// event 是事件。
+func ClaimOutboxEvent(ctx context.Context, event *OutboxEvent) bool {
+	srv := service.GetService()
+	// 已被其他实例认领的事件会推迟下一次尝试的时间，即使读到的是旧的副本也无法再次认领
+	now := time.Now()
+	result := srv.DB.WithContext(ctx).Model(&OutboxEvent{}).Where(&OutboxEvent{
+		ID:     event.ID,
+		Status: OutboxStatusPending,
+	}).Where("attempts = ? AND next_attempt_at <= ?", event.Attempts, now).
+		Update("next_attempt_at", now.Add(config.GetDuration("OUTBOX_CLAIM_TIMEOUT", time.Minute)))
+	if result.Error != nil {
+		slog.Error("调用ORM失败。", "error", result.Error)
+		panic(result.Error)
+	}
+	if result.RowsAffected != 1 {
+		slog.Debug("model.ClaimOutboxEvent: 事件已被其他实例处理", "id", event.ID)
+		return false
+	}
+	return true
+}
+
+// CompleteOutboxEvent 记录事件已经投递成功。
+//
+// ctx 是上下文。
+// event 是事件。
+func CompleteOutboxEvent(ctx context.Context, event *OutboxEvent) {
+	slog.Debug("model.CompleteOutboxEvent: 事件已投递", "id", event.ID, "type", event.EventType)
+	srv := service.GetService()
+	now := time.Now()
+	err := srv.DB.WithContext(ctx).Model(event).Updates(&OutboxEvent{
+		Status:      OutboxStatusDelivered,
+		Attempts:    event.Attempts + 1,
+		DeliveredAt: &now,
+	}).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
+
+// FailOutboxEvent 记录一次投递失败，并按指数退避安排重试，间隔最长为1小时。
+// 重试次数用尽后事件进入死信状态，并通知工作人员。
+// 最大尝试次数由OUTBOX_MAX_ATTEMPTS指定，默认为8次。
+//
+// ctx 是上下文。
+// event 是事件。
+// cause 是失败的原因。
+func FailOutboxEvent(ctx context.Context, event *OutboxEvent, cause error) {
+	slog.Warn("model.FailOutboxEvent: 事件投递失败", "id", event.ID, "type", event.EventType, "attempts", event.Attempts+1, "error", cause)
+	srv := service.GetService()
+	attempts := event.Attempts + 1
+	backoff := time.Hour
+	if attempts < 12 {
+		backoff = time.Second << attempts
+	}
+	update := OutboxEvent{
+		Attempts:      attempts,
+		LastError:     cause.Error(),
+		NextAttemptAt: time.Now().Add(backoff),
+	}
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if attempts >= getOutboxMaxAttempts() {
+			slog.Error("model.FailOutboxEvent: 事件重试次数用尽", "id", event.ID, "type", event.EventType)
+			update.Status = OutboxStatusDead
+			err := createStaffNotice(ctx, tx, NoticeTypeOutboxDead, event.OpenId,
+				"事件"+event.EventType+"多次投递失败，需要人工处理："+cause.Error())
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Model(event).Updates(&update).Error
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
+
+// QueryOutboxEvents 按条件查询事件，按时间倒序排列。
+//
+// ctx 是上下文。
+// query 是查询条件。
+func QueryOutboxEvents(ctx context.Context, query *OutboxEventQuery) *GetOutboxEventListResponse {
+	slog.Debug("model.QueryOutboxEvents: 正在查询事件", "query", query)
+	srv := service.GetService()
+	limit := query.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	var events []OutboxEvent
+	err := srv.DB.WithContext(ctx).Model(&OutboxEvent{}).Where(&OutboxEvent{
+		Status:    query.Status,
+		EventType: query.EventType,
+		OpenId:    query.OpenId,
+	}).Order("id DESC").Limit(limit).Find(&events).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetOutboxEventListResponse{Events: make([]OutboxEventListItem, 0, len(events))}
+	for _, v := range events {
+		result.Events = append(result.Events, OutboxEventListItem{
+			Id:            v.ID,
+			EventType:     v.EventType,
+			OpenId:        v.OpenId,
+			Payload:       json.RawMessage(v.Payload),
+			RequestId:     v.RequestId,
+			Status:        v.Status,
+			Attempts:      v.Attempts,
+			NextAttemptAt: v.NextAttemptAt,
+			LastError:     v.LastError,
+			DeliveredAt:   v.DeliveredAt,
+			CreatedAt:     v.CreatedAt,
+		})
+	}
+	return &result
+}
+
+// RetryOutboxEvent 将死信状态的事件重新放回投递队列，尝试次数从零开始计算。
+//
+// ctx 是上下文。
+// id 是事件的唯一标识符。
+func RetryOutboxEvent(ctx context.Context, id uint) error {
+	slog.Debug("model.RetryOutboxEvent: 正在重试事件", "id", id)
+	srv := service.GetService()
+	var event OutboxEvent
+	err := srv.DB.WithContext(ctx).Model(&OutboxEvent{}).Where(&OutboxEvent{ID: id}).First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &OutboxEventNotFoundError{}
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	if event.Status != OutboxStatusDead {
+		return &OutboxEventNotDeadError{}
+	}
+	before := event
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&event).Select("status", "attempts", "next_attempt_at").Updates(&OutboxEvent{
+			Status:        OutboxStatusPending,
+			Attempts:      0,
+			NextAttemptAt: time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "outbox.retry", AuditTargetOutbox, strconv.FormatUint(uint64(id), 10), &before, &event)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
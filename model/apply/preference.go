@@ -0,0 +1,90 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// TicketPreference 是用户在申请表中填写的组别志愿。
+type TicketPreference struct {
+	gorm.Model
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// GroupId 是志愿组别的唯一标识符。
+	GroupId string `gorm:"type:varchar(36);index"`
+	// Rank 是志愿的顺位，从1开始，数值越小越优先。
+	Rank int `gorm:"type:int"`
+}
+
+type GroupNotInPreferencesError struct{}
+
+func (e *GroupNotInPreferencesError) Error() string {
+	return "该组别不在申请人的志愿中"
+}
+
+// GetTicketGroups 获取用户按顺位排列的志愿组别。
+// 没有志愿记录时退回到申请表中的组别，都未填写时返回空列表。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func GetTicketGroups(ctx context.Context, openid string) []string {
+	slog.Debug("model.GetTicketGroups: 正在获取用户的志愿组别", "openid", openid)
+	preferences := getTicketPreferenceMap(ctx, []string{openid})[openid]
+	if len(preferences) > 0 {
+		return preferences
+	}
+	ticket := findTicket(ctx, openid)
+	if ticket == nil || ticket.Group == "" {
+		return []string{}
+	}
+	return []string{ticket.Group}
+}
+
+// getTicketPreferenceMap 批量获取用户的志愿，键为用户的OpenId。
+//
+// ctx 是上下文。
+// openids 是用户的OpenId列表，为nil时获取全部用户的志愿。
+func getTicketPreferenceMap(ctx context.Context, openids []string) map[string][]string {
+	srv := service.GetService()
+	var preferences []TicketPreference
+	query := srv.DB.WithContext(ctx).Model(&TicketPreference{})
+	if openids != nil {
+		query = query.Where("open_id IN ?", openids)
+	}
+	err := query.Order("open_id, `rank`").Find(&preferences).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := make(map[string][]string)
+	for _, v := range preferences {
+		result[v.OpenId] = append(result[v.OpenId], v.GroupId)
+	}
+	return result
+}
+
+// setTicketPreferences 在事务中替换用户的志愿。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+// groups 是按顺位排列的志愿组别。
+func setTicketPreferences(ctx context.Context, tx *gorm.DB, openid string, groups []string) error {
+	slog.Debug("model.setTicketPreferences: 正在更新用户的志愿", "openid", openid, "groups", groups)
+	err := tx.WithContext(ctx).Unscoped().Where(&TicketPreference{OpenId: openid}).Delete(&TicketPreference{}).Error
+	if err != nil {
+		return err
+	}
+	for i, v := range groups {
+		err := tx.WithContext(ctx).Create(&TicketPreference{
+			OpenId:  openid,
+			GroupId: v,
+			Rank:    i + 1,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// QuestionBody 是管理员创建或更新问题的请求。
+type QuestionBody struct {
+	// Id 是问题ID，创建时为空则自动生成。
+	Id string `json:"id" binding:"omitempty,max=36"`
+	// Question 是问题标题。
+	Question string `json:"question" binding:"required,max=1024"`
+	// Text 是问题的描述，使用Markdown编写。
+	Text string `json:"text"`
+	// SectionId 是问题所属分区的唯一标识符。
+	SectionId string `json:"section_id" binding:"omitempty,max=36"`
+	// Sequence 是问题在所属分区中的顺序。
+	Sequence int `json:"sequence"`
+	// Groups 是可以看到该问题的组别，为空时对所有组别开放。
+	Groups []string `json:"groups"`
+	// VisibleWhen 是问题的显示条件，为空时总是显示。
+	VisibleWhen *QuestionVisibility `json:"visible_when" binding:"omitempty"`
+}
+
+type QuestionRequestUri struct {
+	// Id 是问题ID。
+	Id string `uri:"id" binding:"required"`
+}
+
+type QuestionNotFoundError struct{}
+
+func (e *QuestionNotFoundError) Error() string {
+	return "问题不存在"
+}
+
+type DuplicateQuestionError struct{}
+
+func (e *DuplicateQuestionError) Error() string {
+	return "问题ID已存在"
+}
+
+// GetAllQuestionList 获取全部问题的原始内容，供管理员编辑使用。
+//
+// ctx 是上下文。
+func GetAllQuestionList(ctx context.Context) []QuestionBody {
+	slog.Debug("model.GetAllQuestionList: 正在获取全部问题")
+	srv := service.GetService()
+	var questions []Question
+	err := srv.DB.WithContext(ctx).Model(&Question{}).Order("sequence, id").Find(&questions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	restrictions := GetGroupRestrictionMap(ctx, RestrictionTypeQuestion)
+	result := make([]QuestionBody, 0, len(questions))
+	for _, v := range questions {
+		result = append(result, QuestionBody{
+			Id:          v.QuestionId,
+			Question:    v.Question,
+			Text:        v.Text,
+			SectionId:   v.SectionId,
+			Sequence:    v.Sequence,
+			Groups:      restrictions[v.QuestionId],
+			VisibleWhen: v.VisibleWhen,
+		})
+	}
+	return result
+}
+
+// findQuestionBody 获取问题的原始内容，不存在时返回nil。
+//
+// ctx 是上下文。
+// questionId 是问题ID。
+func findQuestionBody(ctx context.Context, questionId string) *QuestionBody {
+	question := findAuditSnapshot(ctx, &Question{}, &Question{QuestionId: questionId})
+	if question == nil {
+		return nil
+	}
+	return &QuestionBody{
+		Id:          question.QuestionId,
+		Question:    question.Question,
+		Text:        question.Text,
+		SectionId:   question.SectionId,
+		Sequence:    question.Sequence,
+		Groups:      GetGroupRestriction(ctx, RestrictionTypeQuestion, questionId),
+		VisibleWhen: question.VisibleWhen,
+	}
+}
+
+// CheckIsQuestionExists 检查问题是否存在。
+//
+// ctx 是上下文。
+// questionId 是问题ID。
+func CheckIsQuestionExists(ctx context.Context, questionId string) bool {
+	slog.Debug("model.CheckIsQuestionExists: 正在检查问题是否存在", "questionId", questionId)
+	srv := service.GetService()
+	var question Question
+	err := srv.DB.WithContext(ctx).Model(&Question{}).Where(&Question{QuestionId: questionId}).First(&question).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return true
+}
+
+// CreateQuestion 创建问题，并返回问题ID。
+// 已有的文本表单会在用户下次访问时同步新问题。
+// 指定的问题ID已被使用（包括已删除的问题）时返回*DuplicateQuestionError。
+//
+// ctx 是上下文。
+// body 是问题内容。
+func CreateQuestion(ctx context.Context, body *QuestionBody) (string, error) {
+	slog.Debug("model.CreateQuestion: 正在创建问题", "question", body.Question)
+	if body.SectionId != "" && !CheckIsSectionExists(ctx, body.SectionId) {
+		return "", &SectionNotFoundError{}
+	}
+	if !CheckIsGroupListExists(ctx, body.Groups) {
+		return "", &GroupNotFoundError{}
+	}
+	srv := service.GetService()
+	questionId := body.Id
+	if questionId == "" {
+		questionId = uuid.NewString()
+	}
+	err := body.VisibleWhen.validate(questionId, getQuestionConditionMap(ctx))
+	if err != nil {
+		return "", err
+	}
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Unscoped().Model(&Question{}).Where(&Question{QuestionId: questionId}).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return &DuplicateQuestionError{}
+		}
+		err = tx.Create(&Question{
+			QuestionId:  questionId,
+			Question:    body.Question,
+			Text:        body.Text,
+			SectionId:   body.SectionId,
+			Sequence:    body.Sequence,
+			VisibleWhen: body.VisibleWhen,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = SetGroupRestriction(ctx, tx, RestrictionTypeQuestion, questionId, body.Groups)
+		if err != nil {
+			return err
+		}
+		after := *body
+		after.Id = questionId
+		return createAuditLog(ctx, tx, "question.create", AuditTargetQuestion, questionId, nil, &after)
+	})
+	if err != nil {
+		if v, ok := err.(*DuplicateQuestionError); ok {
+			return "", v
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return questionId, nil
+}
+
+// UpdateQuestion 更新问题。
+//
+// ctx 是上下文。
+// questionId 是问题ID。
+// body 是问题内容。
+func UpdateQuestion(ctx context.Context, questionId string, body *QuestionBody) error {
+	slog.Debug("model.UpdateQuestion: 正在更新问题", "questionId", questionId)
+	if !CheckIsQuestionExists(ctx, questionId) {
+		return &QuestionNotFoundError{}
+	}
+	if body.SectionId != "" && !CheckIsSectionExists(ctx, body.SectionId) {
+		return &SectionNotFoundError{}
+	}
+	if !CheckIsGroupListExists(ctx, body.Groups) {
+		return &GroupNotFoundError{}
+	}
+	err := body.VisibleWhen.validate(questionId, getQuestionConditionMap(ctx))
+	if err != nil {
+		return err
+	}
+	before := findQuestionBody(ctx, questionId)
+	srv := service.GetService()
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Question{}).Where(&Question{QuestionId: questionId}).
+			Select("question", "text", "section_id", "sequence", "visible_when").Updates(&Question{
+			Question:    body.Question,
+			Text:        body.Text,
+			SectionId:   body.SectionId,
+			Sequence:    body.Sequence,
+			VisibleWhen: body.VisibleWhen,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = SetGroupRestriction(ctx, tx, RestrictionTypeQuestion, questionId, body.Groups)
+		if err != nil {
+			return err
+		}
+		after := *body
+		after.Id = questionId
+		return createAuditLog(ctx, tx, "question.update", AuditTargetQuestion, questionId, before, &after)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// DeleteQuestion 移除问题。用户对该问题的回答会被隐藏但保留。
+//
+// ctx 是上下文。
+// questionId 是问题ID。
+func DeleteQuestion(ctx context.Context, questionId string) error {
+	slog.Debug("model.DeleteQuestion: 正在移除问题", "questionId", questionId)
+	before := findQuestionBody(ctx, questionId)
+	if before == nil {
+		return &QuestionNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&Question{QuestionId: questionId}).Delete(&Question{}).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "question.delete", AuditTargetQuestion, questionId, before, nil)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// getQuestionConditionMap 获取全部问题的显示条件，以问题ID为键，没有条件的问题的值为nil。
+//
+// ctx 是上下文。
+func getQuestionConditionMap(ctx context.Context) map[string]*QuestionVisibility {
+	srv := service.GetService()
+	var questions []Question
+	err := srv.DB.WithContext(ctx).Model(&Question{}).Select("question_id", "visible_when").Find(&questions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := make(map[string]*QuestionVisibility, len(questions))
+	for _, v := range questions {
+		result[v.QuestionId] = v.VisibleWhen
+	}
+	return result
+}
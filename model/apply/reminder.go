@@ -0,0 +1,137 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// InterviewReminder 记录已经安排发送的面试提醒，用于在重启或多实例运行时避免重复发送。
+// 同一用户、同一房间、同一面试时间的每个提醒时间点只会有一条记录。
+type InterviewReminder struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);uniqueIndex:idx_interview_reminder"`
+	// RoomId 是房间的唯一标识符。
+	RoomId string `gorm:"type:varchar(36);uniqueIndex:idx_interview_reminder"`
+	// RoomTime 是安排提醒时的面试时间，面试时间变化后会重新提醒。
+	RoomTime time.Time `gorm:"type:datetime;uniqueIndex:idx_interview_reminder"`
+	// OffsetSeconds 是提醒时间点距面试开始的秒数。
+	OffsetSeconds int64 `gorm:"type:bigint;uniqueIndex:idx_interview_reminder"`
+}
+
+// InterviewReminderPayload 是面试提醒事件的载荷。
+type InterviewReminderPayload struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid"`
+	// RoomId 是房间的唯一标识符。
+	RoomId string `json:"room_id"`
+	// RoomTime 是面试时间。
+	RoomTime time.Time `json:"room_time"`
+	// OffsetSeconds 是提醒时间点距面试开始的秒数。
+	OffsetSeconds int64 `json:"offset_seconds"`
+}
+
+// reminderCandidate 是可能需要提醒的房间选择。
+type reminderCandidate struct {
+	OpenId    string
+	RoomId    string
+	CreatedAt time.Time
+	Time      time.Time
+}
+
+// GetReminderOffsets 获取面试前发送提醒的时间点，由REMINDER_OFFSETS指定，
+// 格式如“24h,1h”，默认为面试前24小时与1小时。结果从大到小排列，无效的值会被忽略。
+func GetReminderOffsets() []time.Duration {
+	var offsets []time.Duration
+	for _, v := range config.GetList("REMINDER_OFFSETS", []string{"24h", "1h"}) {
+		offset, err := time.ParseDuration(v)
+		if err != nil || offset <= 0 {
+			slog.Warn("model.GetReminderOffsets: 提醒时间点无效", "value", v)
+			continue
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool {
+		return offsets[i] > offsets[j]
+	})
+	return offsets
+}
+
+// ScheduleInterviewReminders 为到达提醒时间点的房间选择写入提醒事件，并返回新安排的提醒数量。
+// 每个时间点只负责到下一个更近的时间点为止，错过的提醒在此之前仍会补发，之后不再补发；
+// 在提醒时间点之后才选择的房间不会收到该时间点的提醒。
+// 提醒记录与事件写在同一个事务中，由唯一索引保证同一提醒只会被安排一次，邮件由事件投递任务发送。
+//
+// ctx 是上下文。
+func ScheduleInterviewReminders(ctx context.Context) int {
+	srv := service.GetService()
+	now := time.Now()
+	offsets := GetReminderOffsets()
+	count := 0
+	for i, offset := range offsets {
+		var next time.Duration
+		if i+1 < len(offsets) {
+			next = offsets[i+1]
+		}
+		var candidates []reminderCandidate
+		err := srv.DB.WithContext(ctx).Model(&Selection{}).
+			Select("selections.open_id, selections.room_id, selections.created_at, rooms.time").
+			Joins("JOIN rooms ON rooms.room_id = selections.room_id AND rooms.deleted_at IS NULL").
+			Where("rooms.available = ? AND rooms.time > ? AND rooms.time <= ?", true, now.Add(next), now.Add(offset)).
+			Scan(&candidates).Error
+		if err != nil {
+			slog.Error("调用ORM失败。", "error", err)
+			panic(err)
+		}
+		for _, v := range candidates {
+			if v.CreatedAt.After(v.Time.Add(-offset)) {
+				continue
+			}
+			if scheduleInterviewReminder(ctx, &v, offset) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// scheduleInterviewReminder 写入一条提醒记录与对应的事件，已经安排过时返回false。
+func scheduleInterviewReminder(ctx context.Context, candidate *reminderCandidate, offset time.Duration) bool {
+	srv := service.GetService()
+	reminder := InterviewReminder{
+		OpenId:        candidate.OpenId,
+		RoomId:        candidate.RoomId,
+		RoomTime:      candidate.Time,
+		OffsetSeconds: int64(offset / time.Second),
+	}
+	scheduled := false
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reminder)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		scheduled = true
+		slog.Debug("model.scheduleInterviewReminder: 正在安排面试提醒", "openid", candidate.OpenId, "roomId", candidate.RoomId, "offset", offset)
+		return enqueueEvent(ctx, tx, OutboxEventInterviewReminder, candidate.OpenId, &InterviewReminderPayload{
+			OpenId:        candidate.OpenId,
+			RoomId:        candidate.RoomId,
+			RoomTime:      candidate.Time,
+			OffsetSeconds: reminder.OffsetSeconds,
+		})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return scheduled
+}
@@ -0,0 +1,324 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+const (
+	// RetentionActionAnonymize 表示清除记录中的个人信息，保留用于统计的字段。
+	RetentionActionAnonymize = "anonymize"
+	// RetentionActionDelete 表示彻底删除记录。
+	RetentionActionDelete = "delete"
+)
+
+// RetentionReport 是执行或预演保留策略的报告。
+type RetentionReport struct {
+	// DryRun 为true时只统计，不会修改任何数据。
+	DryRun bool `json:"dry_run"`
+	// Campaign 是当前招新批次，该批次申请人的数据不会被清除。
+	Campaign string `json:"campaign"`
+	// GeneratedAt 是报告生成的时间。
+	GeneratedAt time.Time `json:"generated_at"`
+	// Items 是各类数据的处理情况。
+	Items []RetentionReportItem `json:"items"`
+}
+
+// RetentionReportItem 是某类数据的处理情况。
+type RetentionReportItem struct {
+	// DataType 是数据的类型。
+	DataType string `json:"data_type"`
+	// Action 是对过期数据的处理方式。
+	Action string `json:"action"`
+	// Enabled 是是否配置了保留期限，未配置时数据会一直保留。
+	Enabled bool `json:"enabled"`
+	// Retention 是保留期限。
+	Retention string `json:"retention"`
+	// Cutoff 是过期的时间界限，早于该时间的数据视为过期。
+	Cutoff *time.Time `json:"cutoff"`
+	// Count 是过期记录的数量，非预演时为实际处理的数量。
+	Count int64 `json:"count"`
+}
+
+// retentionPolicy 是某类数据的保留策略。
+type retentionPolicy struct {
+	// dataType 是数据的类型。
+	dataType string
+	// action 是对过期数据的处理方式。
+	action string
+	// key 是配置保留期限的环境变量，格式如“8760h”，为空或为0时不清除。
+	key string
+	// scope 返回过期记录的查询。
+	scope func(db *gorm.DB, cutoff time.Time) *gorm.DB
+	// purge 处理过期记录，返回处理的数量。
+	purge func(ctx context.Context, query *gorm.DB) (int64, error)
+}
+
+// protectedOpenIds 返回当前批次申请人的OpenId查询，他们的数据不受保留期限影响。
+func protectedOpenIds(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Ticket{}).Select("open_id").
+		Where("campaign = ?", GetCampaign())
+}
+
+// deleteRetained 彻底删除查询到的记录。
+func deleteRetained(model interface{}) func(ctx context.Context, query *gorm.DB) (int64, error) {
+	return func(ctx context.Context, query *gorm.DB) (int64, error) {
+		result := query.Delete(model)
+		return result.RowsAffected, result.Error
+	}
+}
+
+var retentionPolicies = []retentionPolicy{
+	{
+		dataType: "ticket",
+		action:   RetentionActionAnonymize,
+		key:      "RETENTION_TICKET",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Unscoped().Model(&Ticket{}).
+				Where("campaign <> ? AND updated_at < ? AND anonymized_at IS NULL", GetCampaign(), cutoff)
+		},
+		purge: func(ctx context.Context, query *gorm.DB) (int64, error) {
+			// 保留组别、志愿、批次、提交、撤回与录取状态用于统计
+			result := query.Updates(map[string]interface{}{
+				"name":             "",
+				"student_id":       "",
+				"student_id_index": "",
+				"class_name":       "",
+				"contact":          "",
+				"withdraw_reason":  "",
+				"anonymized_at":    time.Now(),
+			})
+			return result.RowsAffected, result.Error
+		},
+	},
+	{
+		dataType: "audit_log",
+		action:   RetentionActionAnonymize,
+		// 撤回原因属于申请表，与申请表使用相同的期限
+		key: "RETENTION_TICKET",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			pattern := `%"withdraw_reason":"_%`
+			return db.Model(&AuditLog{}).
+				Where("action = ? AND created_at < ? AND target_id NOT IN (?)", "ticket.withdraw", cutoff, protectedOpenIds(db)).
+				Where("(`before` LIKE ? OR `after` LIKE ?)", pattern, pattern)
+		},
+		purge: func(ctx context.Context, query *gorm.DB) (int64, error) {
+			var logs []AuditLog
+			err := query.Find(&logs).Error
+			if err != nil {
+				return 0, err
+			}
+			srv := service.GetService()
+			for i := range logs {
+				logs[i].Before = removeAuditField(logs[i].Before, "withdraw_reason")
+				logs[i].After = removeAuditField(logs[i].After, "withdraw_reason")
+				logs[i].Diff = removeAuditField(logs[i].Diff, "withdraw_reason")
+				err := srv.DB.WithContext(ctx).Model(&logs[i]).Select("before", "after", "diff").Updates(&logs[i]).Error
+				if err != nil {
+					return 0, err
+				}
+			}
+			return int64(len(logs)), nil
+		},
+	},
+	{
+		dataType: "ticket_preference",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_TICKET_PREFERENCE",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Unscoped().Model(&TicketPreference{}).
+				Where("updated_at < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: deleteRetained(&TicketPreference{}),
+	},
+	{
+		dataType: "selection",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_SELECTION",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Model(&Selection{}).
+				Where("updated_at < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: func(ctx context.Context, query *gorm.DB) (int64, error) {
+			var selections []Selection
+			err := query.Find(&selections).Error
+			if err != nil {
+				return 0, err
+			}
+			srv := service.GetService()
+			for _, v := range selections {
+				// 同时释放房间的占用，与PurgeAccountData相同
+				err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+					err := tx.Unscoped().Where("id = ?", v.ID).Delete(&Selection{}).Error
+					if err != nil {
+						return err
+					}
+					return tx.Model(&Room{}).Where(&Room{RoomId: v.RoomId}).Where("occupancy > 0").
+						Update("occupancy", gorm.Expr("occupancy - 1")).Error
+				})
+				if err != nil {
+					return 0, err
+				}
+			}
+			return int64(len(selections)), nil
+		},
+	},
+	{
+		dataType: "interview_reminder",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_INTERVIEW_REMINDER",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			// 按面试时间判断，面试已经结束后删除提醒记录不会导致重新发送
+			return db.Model(&InterviewReminder{}).
+				Where("room_time < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: deleteRetained(&InterviewReminder{}),
+	},
+	{
+		dataType: "textform",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_TEXTFORM",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Unscoped().Model(&TextForm{}).
+				Where("updated_at < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: deleteRetained(&TextForm{}),
+	},
+	{
+		dataType: "revision",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_REVISION",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Model(&Revision{}).
+				Where("created_at < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: deleteRetained(&Revision{}),
+	},
+	{
+		dataType: "attachment",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_ATTACHMENT",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Unscoped().Model(&Attachment{}).
+				Where("created_at < ? AND open_id NOT IN (?)", cutoff, protectedOpenIds(db))
+		},
+		purge: func(ctx context.Context, query *gorm.DB) (int64, error) {
+			var attachments []Attachment
+			err := query.Find(&attachments).Error
+			if err != nil {
+				return 0, err
+			}
+			for i := range attachments {
+				removeAttachment(ctx, &attachments[i])
+			}
+			return int64(len(attachments)), nil
+		},
+	},
+	{
+		dataType: "staff_notice",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_STAFF_NOTICE",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Unscoped().Model(&StaffNotice{}).Where("created_at < ?", cutoff)
+		},
+		purge: deleteRetained(&StaffNotice{}),
+	},
+	{
+		dataType: "outbox_event",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_OUTBOX",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			// 只删除已投递的事件，死信需要工作人员处理
+			return db.Model(&OutboxEvent{}).Where("status = ? AND created_at < ?", OutboxStatusDelivered, cutoff)
+		},
+		purge: deleteRetained(&OutboxEvent{}),
+	},
+	{
+		dataType: "webhook_delivery",
+		action:   RetentionActionDelete,
+		key:      "RETENTION_WEBHOOK_DELIVERY",
+		scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+			return db.Model(&WebhookDelivery{}).Where("status = ? AND created_at < ?", WebhookDeliveryStatusDelivered, cutoff)
+		},
+		purge: deleteRetained(&WebhookDelivery{}),
+	},
+}
+
+func init() {
+	// 软删除的记录不会再被读取，超过期限后彻底删除
+	for _, v := range []struct {
+		dataType string
+		model    interface{}
+	}{
+		{"deleted_ticket", &Ticket{}},
+		{"deleted_ticket_preference", &TicketPreference{}},
+		{"deleted_textform", &TextForm{}},
+		{"deleted_selection", &Selection{}},
+	} {
+		model := v.model
+		retentionPolicies = append(retentionPolicies, retentionPolicy{
+			dataType: v.dataType,
+			action:   RetentionActionDelete,
+			key:      "RETENTION_DELETED",
+			scope: func(db *gorm.DB, cutoff time.Time) *gorm.DB {
+				return db.Unscoped().Model(model).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+			},
+			purge: deleteRetained(model),
+		})
+	}
+}
+
+// RunRetentionPolicy 按保留策略清除过期的个人数据。
+// 各类数据的保留期限由对应的环境变量指定，未配置时不会清除；当前批次申请人的数据总是保留。
+//
+// ctx 是上下文。
+// dryRun 为true时只统计过期记录的数量，不修改任何数据。
+func RunRetentionPolicy(ctx context.Context, dryRun bool) *RetentionReport {
+	slog.Debug("model.RunRetentionPolicy: 正在执行保留策略", "dryRun", dryRun)
+	srv := service.GetService()
+	now := time.Now()
+	report := RetentionReport{
+		DryRun:      dryRun,
+		Campaign:    GetCampaign(),
+		GeneratedAt: now,
+		Items:       make([]RetentionReportItem, 0, len(retentionPolicies)),
+	}
+	for _, policy := range retentionPolicies {
+		retention := config.GetDuration(policy.key, 0)
+		item := RetentionReportItem{
+			DataType:  policy.dataType,
+			Action:    policy.action,
+			Enabled:   retention > 0,
+			Retention: retention.String(),
+		}
+		if !item.Enabled {
+			report.Items = append(report.Items, item)
+			continue
+		}
+		cutoff := now.Add(-retention)
+		item.Cutoff = &cutoff
+		db := srv.DB.WithContext(ctx)
+		var err error
+		if dryRun {
+			err = policy.scope(db, cutoff).Count(&item.Count).Error
+		} else {
+			item.Count, err = policy.purge(ctx, policy.scope(db, cutoff))
+		}
+		if err != nil {
+			slog.Error("调用ORM失败。", "error", err)
+			panic(err)
+		}
+		if !dryRun && item.Count > 0 {
+			slog.Info("model.RunRetentionPolicy: 已清除过期数据", "dataType", policy.dataType, "action", policy.action, "count", item.Count)
+		}
+		report.Items = append(report.Items, item)
+	}
+	if !dryRun {
+		recordAuditLog(ctx, "retention.purge", AuditTargetRetention, "", nil, &report)
+	}
+	return &report
+}
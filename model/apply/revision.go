@@ -0,0 +1,227 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/request"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+const (
+	// RevisionTypeTextForm 是文本表单回答的修订。
+	RevisionTypeTextForm = "textform"
+	// RevisionTypeTicket 是申请表的修订。
+	RevisionTypeTicket = "ticket"
+)
+
+// Revision 是用户每次保存文本表单或申请表时留下的修订记录。
+// 修订记录只会追加，不会被修改或删除。
+type Revision struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	// OpenId 是用户的OpenId。
+	OpenId string `gorm:"type:varchar(40);index"`
+	// Type 是修订的类型，为“textform”或“ticket”。
+	Type string `gorm:"type:varchar(16)"`
+	// TargetId 是修订对应的问题ID，申请表的修订为空。
+	TargetId string `gorm:"type:varchar(36)"`
+	// Value 是本次保存的值，申请表的修订为TicketBody的JSON，加密保存。
+	Value string `gorm:"type:text;serializer:encrypted"`
+	// RequestId 是本次保存所属请求的请求ID。
+	RequestId string `gorm:"type:varchar(64)"`
+}
+
+// RevisionListItem 是修订列表项。
+type RevisionListItem struct {
+	// Id 是修订的唯一标识符。
+	Id uint `json:"id"`
+	// OpenId 是用户的OpenId。
+	OpenId string `json:"openid,omitempty"`
+	// Type 是修订的类型。
+	Type string `json:"type"`
+	// TargetId 是修订对应的问题ID。
+	TargetId string `json:"target_id,omitempty"`
+	// Value 是本次保存的值。
+	Value string `json:"value"`
+	// RequestId 是本次保存所属请求的请求ID。
+	RequestId string `json:"request_id"`
+	// CreatedAt 是保存时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetRevisionListResponse 是获取修订列表的响应。
+type GetRevisionListResponse struct {
+	Revisions []RevisionListItem `json:"revisions"`
+}
+
+type RestoreRevisionRequestUri struct {
+	// Id 是问题ID，仅文本表单使用。
+	Id string `uri:"id"`
+	// Revision 是修订的唯一标识符。
+	Revision uint `uri:"revision" binding:"required"`
+}
+
+// RevisionQuery 是管理员查询修订记录的条件。
+type RevisionQuery struct {
+	// OpenId 是用户的OpenId。
+	OpenId string `form:"openid"`
+	// Type 是修订的类型。
+	Type string `form:"type" binding:"omitempty,oneof=textform ticket"`
+	// TargetId 是修订对应的问题ID。
+	TargetId string `form:"target_id"`
+	// Limit 是返回的最大条数，默认为100。
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+type RevisionNotFoundError struct{}
+
+func (e *RevisionNotFoundError) Error() string {
+	return "修订记录不存在"
+}
+
+// createRevision 在事务中记录一次保存。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+// revisionType 是修订的类型。
+// targetId 是修订对应的问题ID。
+// value 是本次保存的值。
+func createRevision(ctx context.Context, tx *gorm.DB, openid string, revisionType string, targetId string, value string) error {
+	slog.Debug("model.createRevision: 正在记录修订", "openid", openid, "type", revisionType, "targetId", targetId)
+	return tx.WithContext(ctx).Create(&Revision{
+		OpenId:    openid,
+		Type:      revisionType,
+		TargetId:  targetId,
+		Value:     value,
+		RequestId: request.GetRequestId(ctx),
+	}).Error
+}
+
+// GetRevisionList 获取用户自己的修订列表，按时间倒序排列。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// revisionType 是修订的类型。
+// targetId 是修订对应的问题ID。
+func GetRevisionList(ctx context.Context, openid string, revisionType string, targetId string) *GetRevisionListResponse {
+	slog.Debug("model.GetRevisionList: 正在获取修订列表", "openid", openid, "type", revisionType, "targetId", targetId)
+	return QueryRevisionList(ctx, &RevisionQuery{
+		OpenId:   openid,
+		Type:     revisionType,
+		TargetId: targetId,
+	})
+}
+
+// QueryRevisionList 按条件查询修订列表，按时间倒序排列。
+//
+// ctx 是上下文。
+// query 是查询条件。
+func QueryRevisionList(ctx context.Context, query *RevisionQuery) *GetRevisionListResponse {
+	slog.Debug("model.QueryRevisionList: 正在查询修订列表", "query", query)
+	srv := service.GetService()
+	limit := query.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	var revisions []Revision
+	err := srv.DB.WithContext(ctx).Model(&Revision{}).Where(&Revision{
+		OpenId:   query.OpenId,
+		Type:     query.Type,
+		TargetId: query.TargetId,
+	}).Order("id DESC").Limit(limit).Find(&revisions).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetRevisionListResponse{Revisions: make([]RevisionListItem, 0, len(revisions))}
+	for _, v := range revisions {
+		result.Revisions = append(result.Revisions, RevisionListItem{
+			Id:        v.ID,
+			OpenId:    v.OpenId,
+			Type:      v.Type,
+			TargetId:  v.TargetId,
+			Value:     v.Value,
+			RequestId: v.RequestId,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return &result
+}
+
+// getOwnRevision 获取属于用户的某条修订。
+func getOwnRevision(ctx context.Context, openid string, revisionType string, targetId string, revisionId uint) (*Revision, error) {
+	srv := service.GetService()
+	var revision Revision
+	err := srv.DB.WithContext(ctx).Model(&Revision{}).Where(&Revision{
+		ID:       revisionId,
+		OpenId:   openid,
+		Type:     revisionType,
+		TargetId: targetId,
+	}).First(&revision).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Debug("model.getOwnRevision: 修订记录不存在", "openid", openid, "revision", revisionId)
+			return nil, &RevisionNotFoundError{}
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &revision, nil
+}
+
+// RestoreTextFormRevision 将用户的文本表单回答恢复为某条修订的值。
+// 恢复本身也会产生一条新的修订。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// questionId 是问题ID。
+// revisionId 是修订的唯一标识符。
+func RestoreTextFormRevision(ctx context.Context, openid string, questionId string, revisionId uint) error {
+	slog.Debug("model.RestoreTextFormRevision: 正在恢复文本表单", "openid", openid, "questionId", questionId, "revision", revisionId)
+	revision, err := getOwnRevision(ctx, openid, RevisionTypeTextForm, questionId, revisionId)
+	if err != nil {
+		return err
+	}
+	return UpdateTextForm(ctx, openid, &UpdateTextFormRequest{
+		Id:     questionId,
+		Answer: revision.Value,
+	})
+}
+
+// RestoreTicketRevision 将用户的申请表恢复为某条修订的值。
+// 恢复本身也会产生一条新的修订，且需要通过与提交时相同的校验。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// revisionId 是修订的唯一标识符。
+func RestoreTicketRevision(ctx context.Context, openid string, revisionId uint) error {
+	slog.Debug("model.RestoreTicketRevision: 正在恢复申请表", "openid", openid, "revision", revisionId)
+	body, err := GetTicketRevision(ctx, openid, revisionId)
+	if err != nil {
+		return err
+	}
+	return UpdateTicket(ctx, openid, body)
+}
+
+// GetTicketRevision 获取用户某条申请表修订的内容。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// revisionId 是修订的唯一标识符。
+func GetTicketRevision(ctx context.Context, openid string, revisionId uint) (*TicketBody, error) {
+	revision, err := getOwnRevision(ctx, openid, RevisionTypeTicket, "", revisionId)
+	if err != nil {
+		return nil, err
+	}
+	var body TicketBody
+	err = json.Unmarshal([]byte(revision.Value), &body)
+	if err != nil {
+		slog.Error("model.GetTicketRevision: 无法解析修订", "error", err)
+		panic(err)
+	}
+	return &body, nil
+}
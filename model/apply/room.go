@@ -3,9 +3,14 @@ package apply
 import (
 	"context"
 	"elab-backend/service"
+	"elab-backend/util/validate"
+	"encoding/base64"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,6 +21,24 @@ type SetRoomSelectionRequest struct {
 
 type GetRoomListResponse struct {
 	Rooms []RoomListItem `json:"rooms"`
+	// NextCursor 是获取下一页时使用的cursor，没有更多房间时为空。
+	NextCursor string `json:"next_cursor"`
+}
+
+// RoomQuery 是用户查询房间列表的条件，结果按面试时间排序。
+type RoomQuery struct {
+	// Date 是招新时区中的面试日期，格式为“YYYY-MM-DD”，为空时不限日期。
+	Date string `form:"date"`
+	// Location 是房间地点。
+	Location string `form:"location" binding:"omitempty,max=255"`
+	// Group 是组别ID，只返回对该组别开放的房间。
+	Group string `form:"group" binding:"omitempty,max=36"`
+	// HasFreeSeats 为true时只返回还有空位的房间。
+	HasFreeSeats bool `form:"has_free_seats"`
+	// Cursor 是上一页返回的NextCursor。
+	Cursor string `form:"cursor"`
+	// Limit 是返回的最大条数，默认为50。
+	Limit int `form:"limit" binding:"omitempty,min=1,max=200"`
 }
 
 type RoomListItem struct {
@@ -23,19 +46,71 @@ type RoomListItem struct {
 	Id string `json:"id"`
 	// Name 是房间的名称。
 	Name string `json:"name"`
-	// Time 是面试时间。
+	// Time 是面试开始时间，为带时区的RFC3339格式。
 	Time *time.Time `json:"time"`
+	// EndTime 是面试结束时间，为带时区的RFC3339格式。
+	EndTime *time.Time `json:"end_time"`
 	// Capacity 是房间的容量。
 	Capacity int `json:"capacity"`
 	// Occupancy 是房间的占用情况。
 	Occupancy int `json:"occupancy"`
+	// Held 是房间中被临时保留的座位数量，空位数量为Capacity-Occupancy-Held。
+	Held int `json:"held"`
 	// Location 是房间地点。
 	Location string `json:"location"`
 }
 
+// RoomAdminItem 是管理员查看的房间信息。
+type RoomAdminItem struct {
+	RoomListItem
+	// Available 是房间是否可用。
+	Available bool `json:"available"`
+	// Groups 是可以选择该房间的组别，为空时对所有组别开放。
+	Groups []string `json:"groups"`
+}
+
+type RoomRequestUri struct {
+	// Id 是房间的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+}
+
+// SetRoomGroupRequest 是管理员设置房间组别限制的请求。
+type SetRoomGroupRequest struct {
+	// Groups 是可以选择该房间的组别，为空时对所有组别开放。
+	Groups []string `json:"groups"`
+}
+
+// RoomBody 是管理员创建或更新房间的请求。
+type RoomBody struct {
+	// Name 是房间的名称。
+	Name string `json:"name" binding:"required,max=255"`
+	// Time 是面试开始时间，为带时区的RFC3339格式。
+	Time time.Time `json:"time" binding:"required"`
+	// EndTime 是面试结束时间，为空时按默认的面试时长计算。
+	EndTime *time.Time `json:"end_time"`
+	// Capacity 是房间的容量。
+	Capacity int `json:"capacity" binding:"min=0"`
+	// Location 是房间地点，同一地点的可用房间时间不能重叠。
+	Location string `json:"location" binding:"required,max=255"`
+	// Available 是房间是否可用，创建时默认为可用。
+	Available *bool `json:"available"`
+}
+
 type GetRoomDateListResponse struct {
 	// DateList 是房间的日期列表。
 	Dates []string `json:"dates"`
+	// Details 是每个日期的房间数量与空位数量，与Dates的顺序相同。
+	Details []RoomDateItem `json:"details"`
+}
+
+// RoomDateItem 是某个日期的房间统计。
+type RoomDateItem struct {
+	// Date 是招新时区中的日期。
+	Date string `json:"date"`
+	// Rooms 是房间数量。
+	Rooms int64 `json:"rooms"`
+	// FreeSeats 是空位数量。
+	FreeSeats int64 `json:"free_seats"`
 }
 
 // Room 是面试房间的数据库模型。
@@ -45,12 +120,16 @@ type Room struct {
 	RoomId string `gorm:"type:varchar(36)"`
 	// Name 是房间的名称。
 	Name string `gorm:"type:varchar(255)"`
-	// Time 是面试时间。
+	// Time 是面试开始时间。
 	Time *time.Time `gorm:"type:datetime"`
+	// EndTime 是面试结束时间，为空时按INTERVIEW_DURATION计算，见GetEnd。
+	EndTime *time.Time `gorm:"type:datetime"`
 	// Capacity 是房间的容量。
 	Capacity int `gorm:"type:int"`
 	// Occupancy 是房间的占用情况。
 	Occupancy int `gorm:"type:int"`
+	// Held 是房间中被临时保留、尚未确认的座位数量，见SeatHold。
+	Held int `gorm:"type:int;default:0"`
 	// Location 是房间地点。
 	Location string `gorm:"type:varchar(255)"`
 	// Available 是房间是否可用。
@@ -75,80 +154,190 @@ func (e *DuplicateSelectionError) Error() string {
 	return "重复选择房间"
 }
 
+type RoomGroupMismatchError struct{}
+
+func (e *RoomGroupMismatchError) Error() string {
+	return "该房间不面向你志愿中的组别"
+}
+
+type RoomOverlapError struct {
+	// Name 是时间重叠的房间的名称。
+	Name string
+}
+
+func (e *RoomOverlapError) Error() string {
+	return "与同一地点的房间“" + e.Name + "”时间重叠"
+}
+
+type InvalidCursorError struct{}
+
+func (e *InvalidCursorError) Error() string {
+	return "cursor无效"
+}
+
 type SelectionNotFoundError struct{}
 
 func (e *SelectionNotFoundError) Error() string {
 	return "用户未选择房间"
 }
 
-// GetRoomList 获取房间列表。
-// `
+// GetRoomList 获取用户可选的房间列表，按面试时间排序，仅限用户志愿以外组别的房间不会出现在列表中。
+// 日期格式错误时返回*InvalidDateError，cursor无效时返回*InvalidCursorError。
+//
 // ctx 是上下文。
-// date 是面试日期，格式为“YYYY-MM-DD”。
-func GetRoomList(ctx context.Context, date string) *GetRoomListResponse {
-	var rooms []Room
+// openid 是用户的Openid。
+// query 是查询条件。
+func GetRoomList(ctx context.Context, openid string, query *RoomQuery) (*GetRoomListResponse, error) {
+	slog.Debug("model.GetRoomList: 正在获取房间列表", "openid", openid, "query", query)
 	srv := service.GetService()
-	timeStart := date + " 00:00:00"
-	timeEnd := date + " 23:59:59"
-	slog.Debug("model.GetRoomList: 正在获取房间列表", "timeStart", timeStart, "timeEnd", timeEnd)
-	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{
+	db := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{
 		Available: &[]bool{true}[0],
-	}).Where("time BETWEEN ? AND ?", timeStart, timeEnd).Find(&rooms).Error
+		Location:  query.Location,
+	}).Where("time IS NOT NULL").Scopes(allowedForGroups(RestrictionTypeRoom, "room_id", GetTicketGroups(ctx, openid)))
+	if query.Date != "" {
+		timeStart, timeEnd, err := ParseCampaignDate(query.Date)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("time >= ? AND time < ?", timeStart, timeEnd)
+	}
+	if query.Group != "" {
+		db = db.Scopes(allowedForGroups(RestrictionTypeRoom, "room_id", []string{query.Group}))
+	}
+	if query.HasFreeSeats {
+		db = db.Where("occupancy + held < capacity")
+	}
+	if query.Cursor != "" {
+		cursorTime, cursorId, err := decodeRoomCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("(time > ? OR (time = ? AND id > ?))", cursorTime, cursorTime, cursorId)
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = 50
+	}
+	var rooms []Room
+	err := db.Order("time, id").Limit(limit).Find(&rooms).Error
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
-	var res []RoomListItem
+	result := GetRoomListResponse{Rooms: make([]RoomListItem, 0, len(rooms))}
 	for _, room := range rooms {
-		res = append(res, RoomListItem{
-			Id:        room.RoomId,
-			Name:      room.Name,
-			Time:      room.Time,
-			Capacity:  room.Capacity,
-			Occupancy: room.Occupancy,
-			Location:  room.Location,
-		})
+		result.Rooms = append(result.Rooms, newRoomListItem(&room))
 	}
-	return &GetRoomListResponse{
-		Rooms: res,
+	if len(rooms) == limit {
+		result.NextCursor = encodeRoomCursor(&rooms[len(rooms)-1])
 	}
+	return &result, nil
 }
 
-// GetRoomDateList 获取房间日期列表。
+// encodeRoomCursor 将房间的排序位置编码为cursor。
+func encodeRoomCursor(room *Room) string {
+	value := room.Time.UTC().Format(time.RFC3339Nano) + "|" + strconv.FormatUint(uint64(room.ID), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodeRoomCursor 解析cursor，返回上一页最后一个房间的面试时间与ID。
+func decodeRoomCursor(cursor string) (time.Time, uint, error) {
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, &InvalidCursorError{}
+	}
+	parts := strings.SplitN(string(value), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, &InvalidCursorError{}
+	}
+	cursorTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, 0, &InvalidCursorError{}
+	}
+	cursorId, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, &InvalidCursorError{}
+	}
+	return cursorTime, uint(cursorId), nil
+}
+
+// GetRoomDateList 获取用户可选房间的日期列表及每天的空位数量，日期按招新时区划分。
+// 数据库按面试开始时间分组统计，再在招新时区中合并为日期，不会读取每个房间。
 //
 // ctx 是上下文。
-func GetRoomDateList(ctx context.Context) *GetRoomDateListResponse {
-	var rooms []Room
+// openid 是用户的Openid。
+func GetRoomDateList(ctx context.Context, openid string) *GetRoomDateListResponse {
+	slog.Debug("model.GetRoomDateList: 正在获取房间日期列表", "openid", openid)
 	srv := service.GetService()
-	slog.Debug("model.GetRoomDateList: 正在获取房间日期列表")
-	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{
-		Available: &[]bool{true}[0],
-	}).Find(&rooms).Error
+	var slots []struct {
+		Time      time.Time
+		Rooms     int64
+		FreeSeats int64
+	}
+	err := srv.DB.WithContext(ctx).Model(&Room{}).
+		Select("time, COUNT(*) AS rooms, SUM(GREATEST(capacity - occupancy - held, 0)) AS free_seats").
+		Where(&Room{Available: &[]bool{true}[0]}).Where("time IS NOT NULL").
+		Scopes(allowedForGroups(RestrictionTypeRoom, "room_id", GetTicketGroups(ctx, openid))).
+		Group("time").Order("time").Scan(&slots).Error
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
-	var dates []string
-	for _, room := range rooms {
-		dates = append(dates, room.Time.Format("2006-01-02"))
+	result := GetRoomDateListResponse{
+		Dates:   make([]string, 0),
+		Details: make([]RoomDateItem, 0),
 	}
-	// 去重
-	dates = removeDuplicateElement(dates)
-	return &GetRoomDateListResponse{
-		Dates: dates,
+	for _, v := range slots {
+		date := v.Time.In(GetCampaignLocation()).Format("2006-01-02")
+		last := len(result.Details) - 1
+		if last >= 0 && result.Details[last].Date == date {
+			result.Details[last].Rooms += v.Rooms
+			result.Details[last].FreeSeats += v.FreeSeats
+			continue
+		}
+		result.Dates = append(result.Dates, date)
+		result.Details = append(result.Details, RoomDateItem{
+			Date:      date,
+			Rooms:     v.Rooms,
+			FreeSeats: v.FreeSeats,
+		})
 	}
+	return &result
 }
 
-func removeDuplicateElement(a []string) []string {
-	result := make([]string, 0)
-	temp := map[string]struct{}{}
-	for _, item := range a {
-		if _, ok := temp[item]; !ok {
-			temp[item] = struct{}{}
-			result = append(result, item)
-		}
+// GetEnd 获取面试结束时间，未设置时为开始时间加上默认的面试时长，未安排时间时返回nil。
+func (room *Room) GetEnd() *time.Time {
+	if room.EndTime != nil {
+		return room.EndTime
 	}
-	return result
+	if room.Time == nil {
+		return nil
+	}
+	end := room.Time.Add(GetInterviewDuration())
+	return &end
+}
+
+// newRoomListItem 创建房间列表项，时间转换为招新时区。
+func newRoomListItem(room *Room) RoomListItem {
+	return RoomListItem{
+		Id:        room.RoomId,
+		Name:      room.Name,
+		Time:      inCampaignLocation(room.Time),
+		EndTime:   inCampaignLocation(room.GetEnd()),
+		Capacity:  room.Capacity,
+		Occupancy: room.Occupancy,
+		Held:      room.Held,
+		Location:  room.Location,
+	}
+}
+
+// inCampaignLocation 将时间转换为招新时区，为nil时返回nil。
+func inCampaignLocation(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	result := t.In(GetCampaignLocation())
+	return &result
 }
 
 // Selection 是用户的房间选择的数据库模型。
@@ -160,7 +349,7 @@ type Selection struct {
 	RoomId string `gorm:"type:varchar(36)"`
 }
 
-// SetSelection 设置用户的房间选择。
+// SetSelection 设置用户的房间选择。其他用户保留的座位计入容量，用户自己的座位保留会被一并取消。
 //
 // ctx 是上下文。
 // openid 是用户的Openid。
@@ -174,6 +363,12 @@ func SetSelection(ctx context.Context, openid string, roomId string) error {
 		slog.Error("model.SetSelection: 房间不存在", "roomId", roomId)
 		return &RoomNotFoundError{}
 	}
+	// 检测房间是否面向用户的组别
+	groups := GetTicketGroups(ctx, openid)
+	if !isAllowedForGroups(GetGroupRestriction(ctx, RestrictionTypeRoom, roomId), groups) {
+		slog.Error("model.SetSelection: 房间不面向用户的组别", "roomId", roomId, "groups", groups)
+		return &RoomGroupMismatchError{}
+	}
 	// 先获取用户是否已经选择了房间
 	selectedRoomId, isAlreadySelected := CheckIsAlreadySelected(ctx, openid)
 	if isAlreadySelected {
@@ -183,13 +378,6 @@ func SetSelection(ctx context.Context, openid string, roomId string) error {
 			slog.Error("model.SetSelection: 用户选择的房间与之前相同，无需更改", "openid", openid)
 			return &DuplicateSelectionError{}
 		}
-		slog.Debug("model.SetSelection: 用户选择的房间与之前不同，正在移除之前的选择", "openid", openid, "roomId", roomId)
-		// 前后房间不同，先移除之前的选择
-		err := RemoveSelection(ctx, openid, selectedRoomId)
-		if err != nil {
-			slog.Error("model.SetSelection: 移除用户之前的选择失败", "openid", openid, "roomId", roomId)
-			panic(err)
-		}
 	}
 	targetRoom := Room{
 		RoomId:    roomId,
@@ -201,28 +389,75 @@ func SetSelection(ctx context.Context, openid string, roomId string) error {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
-	// 检测房间是否已满
-	isFull := targetRoom.Occupancy >= targetRoom.Capacity
+	// 检测房间是否已满，房间已满时保留用户之前的选择；其他用户保留的座位也计入，用户自己在该房间的保留除外
+	held := targetRoom.Held
+	if hasSeatHold(ctx, openid, roomId) {
+		held--
+	}
+	isFull := targetRoom.Occupancy+held >= targetRoom.Capacity
 	if isFull {
 		slog.Error("model.SetSelection: 房间已满", "roomId", roomId)
 		return &RoomFullError{}
 	}
-	selection := Selection{
-		OpenId: openid,
-		RoomId: roomId,
-	}
-	err = srv.DB.WithContext(ctx).Create(&selection).Error
+	var releasedRoomId string
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 直接选择房间时用户的座位保留不再需要
+		var err error
+		releasedRoomId, err = releaseSeatHold(tx, openid)
+		if err != nil {
+			return err
+		}
+		return applySelection(ctx, tx, openid, roomId, selectedRoomId)
+	})
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
-	err = srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{
+	publishRoomOccupancy(ctx, roomId, selectedRoomId, releasedRoomId)
+	return nil
+}
+
+// applySelection 在事务中将用户的房间选择设置为roomId，替换之前的选择，并记录审计日志与事件。
+// 调用方需要事先检查房间是否可选。
+//
+// ctx 是上下文。
+// tx 是当前事务。
+// openid 是用户的Openid。
+// roomId 是房间的唯一标识符。
+// selectedRoomId 是用户之前选择的房间，没有选择时为空。
+func applySelection(ctx context.Context, tx *gorm.DB, openid string, roomId string, selectedRoomId string) error {
+	var before *SetRoomSelectionRequest
+	if selectedRoomId != "" {
+		slog.Debug("model.applySelection: 用户选择的房间与之前不同，正在移除之前的选择", "openid", openid, "roomId", selectedRoomId)
+		err := releaseSelection(tx, openid, selectedRoomId)
+		if err != nil {
+			return err
+		}
+		before = &SetRoomSelectionRequest{Id: selectedRoomId}
+	}
+	err := tx.Create(&Selection{
+		OpenId: openid,
+		RoomId: roomId,
+	}).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Model(&Room{}).Where(&Room{
 		RoomId:    roomId,
 		Available: &[]bool{true}[0],
-	}).Updates(&Room{
-		Occupancy: targetRoom.Occupancy + 1,
-	}).Error
-	return nil
+	}).Update("occupancy", gorm.Expr("occupancy + 1")).Error
+	if err != nil {
+		return err
+	}
+	err = createAuditLog(ctx, tx, "selection.set", AuditTargetSelection, openid, before, &SetRoomSelectionRequest{Id: roomId})
+	if err != nil {
+		return err
+	}
+	return enqueueEvent(ctx, tx, OutboxEventSelectionChanged, openid, &SelectionChangedPayload{
+		OpenId:         openid,
+		RoomId:         roomId,
+		PreviousRoomId: selectedRoomId,
+	})
 }
 
 func CheckIsRoomExists(ctx context.Context, roomId string) bool {
@@ -274,61 +509,53 @@ func ClearSelection(ctx context.Context, openid string) error {
 		panic(err)
 	}
 	roomId := selection.RoomId
-	slog.Debug("model.ClearSelection: 正在移除用户的房间选择", "openid", openid)
-	err = srv.DB.WithContext(ctx).Model(&Selection{}).Where(&selection).Delete(&Selection{}).Error
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		slog.Debug("model.ClearSelection: 正在移除用户的房间选择", "openid", openid)
+		return clearSelection(ctx, tx, openid, roomId)
+	})
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
-	slog.Debug("model.ClearSelection: 正在更新房间占用情况", "openid", openid)
-	targetRoom := Room{
-		RoomId:    roomId,
-		Available: &[]bool{true}[0],
-	}
-	err = srv.DB.WithContext(ctx).Model(&Room{}).Where(&targetRoom).First(&targetRoom).Error
+	publishRoomOccupancy(ctx, roomId)
+	return nil
+}
+
+// clearSelection 在事务中移除用户的房间选择，并记录审计日志和选择变更事件。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+// roomId 是用户选择的房间的唯一标识符。
+func clearSelection(ctx context.Context, tx *gorm.DB, openid string, roomId string) error {
+	err := releaseSelection(tx, openid, roomId)
 	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
+		return err
 	}
-	targetRoom.Occupancy--
-	err = srv.DB.Save(&targetRoom).Error
+	err = createAuditLog(ctx, tx, "selection.clear", AuditTargetSelection, openid, &SetRoomSelectionRequest{Id: roomId}, nil)
 	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
+		return err
 	}
-	return nil
+	return enqueueEvent(ctx, tx, OutboxEventSelectionChanged, openid, &SelectionChangedPayload{
+		OpenId:         openid,
+		PreviousRoomId: roomId,
+	})
 }
 
-func RemoveSelection(ctx context.Context, openid string, roomId string) error {
-	slog.Debug("model.RemoveSelection: 正在移除用户的房间选择", "openid", openid, "roomId", roomId)
-	srv := service.GetService()
-	selection := Selection{
+// releaseSelection 在事务中移除用户对某个房间的选择，并释放房间的占用。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+// roomId 是房间的唯一标识符。
+func releaseSelection(tx *gorm.DB, openid string, roomId string) error {
+	err := tx.Where(&Selection{
 		OpenId: openid,
 		RoomId: roomId,
-	}
-	err := srv.DB.WithContext(ctx).Where(&selection).Delete(&selection).Error
-	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
-	}
-	slog.Debug("model.RemoveSelection 正在更新房间占用情况", "roomId", roomId)
-	targetRoom := Room{
-		RoomId:    roomId,
-		Available: &[]bool{true}[0],
-	}
-	err = srv.DB.WithContext(ctx).Model(&Room{}).Where(&targetRoom).First(&targetRoom).Error
-	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
-	}
-	slog.Debug("model.RemoveSelection 房间目前数量", "roomId", roomId, "occupancy", targetRoom.Occupancy)
-	targetRoom.Occupancy--
-	err = srv.DB.WithContext(ctx).Save(&targetRoom).Error
+	}).Delete(&Selection{}).Error
 	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
+		return err
 	}
-	return nil
+	return tx.Model(&Room{}).Where(&Room{RoomId: roomId}).Where("occupancy > 0").
+		Update("occupancy", gorm.Expr("occupancy - 1")).Error
 }
 
 func CheckIsSelectionExists(ctx context.Context, openid string) bool {
@@ -367,3 +594,187 @@ func GetSelection(ctx context.Context, openid string) (*Selection, error) {
 	}
 	return &selection, nil
 }
+
+// GetAllRoomList 获取全部房间及其组别限制，供管理员使用。
+//
+// ctx 是上下文。
+func GetAllRoomList(ctx context.Context) []RoomAdminItem {
+	slog.Debug("model.GetAllRoomList: 正在获取全部房间")
+	srv := service.GetService()
+	var rooms []Room
+	err := srv.DB.WithContext(ctx).Model(&Room{}).Order("time, id").Find(&rooms).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	restrictions := GetGroupRestrictionMap(ctx, RestrictionTypeRoom)
+	result := make([]RoomAdminItem, 0, len(rooms))
+	for _, room := range rooms {
+		result = append(result, RoomAdminItem{
+			RoomListItem: newRoomListItem(&room),
+			Available:    room.Available != nil && *room.Available,
+			Groups:       restrictions[room.RoomId],
+		})
+	}
+	return result
+}
+
+// SetRoomGroupRestriction 设置房间的组别限制。
+// 已经选择了该房间的用户不受影响。
+//
+// ctx 是上下文。
+// roomId 是房间的唯一标识符。
+// groups 是可以选择该房间的组别。
+func SetRoomGroupRestriction(ctx context.Context, roomId string, groups []string) error {
+	slog.Debug("model.SetRoomGroupRestriction: 正在设置房间的组别限制", "roomId", roomId, "groups", groups)
+	srv := service.GetService()
+	var count int64
+	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{RoomId: roomId}).Count(&count).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	if count == 0 {
+		return &RoomNotFoundError{}
+	}
+	if !CheckIsGroupListExists(ctx, groups) {
+		return &GroupNotFoundError{}
+	}
+	before := GetGroupRestriction(ctx, RestrictionTypeRoom, roomId)
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := SetGroupRestriction(ctx, tx, RestrictionTypeRoom, roomId, groups)
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "room.group.update", AuditTargetRoom, roomId,
+			&SetRoomGroupRequest{Groups: before}, &SetRoomGroupRequest{Groups: groups})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// CreateRoom 创建房间，并返回房间的唯一标识符。
+// 结束时间不晚于开始时间时返回*validate.FieldError，与同一地点的可用房间时间重叠时返回*RoomOverlapError。
+//
+// ctx 是上下文。
+// body 是房间内容。
+func CreateRoom(ctx context.Context, body *RoomBody) (string, error) {
+	slog.Debug("model.CreateRoom: 正在创建房间", "name", body.Name)
+	available := body.Available
+	if available == nil {
+		available = &[]bool{true}[0]
+	}
+	room := Room{
+		RoomId:    uuid.NewString(),
+		Name:      body.Name,
+		Time:      &body.Time,
+		EndTime:   body.EndTime,
+		Capacity:  body.Capacity,
+		Location:  body.Location,
+		Available: available,
+	}
+	err := validateRoom(ctx, &room)
+	if err != nil {
+		return "", err
+	}
+	srv := service.GetService()
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&room).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "room.create", AuditTargetRoom, room.RoomId, nil, &room)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return room.RoomId, nil
+}
+
+// UpdateRoom 更新房间，已经选择了该房间的用户不受影响。
+// 除CreateRoom中的校验外，容量小于已选择与已保留的人数时也会返回*validate.FieldError。
+// 调用方需要持有房间选择的锁。
+//
+// ctx 是上下文。
+// roomId 是房间的唯一标识符。
+// body 是房间内容。
+func UpdateRoom(ctx context.Context, roomId string, body *RoomBody) error {
+	slog.Debug("model.UpdateRoom: 正在更新房间", "roomId", roomId)
+	before := findAuditSnapshot(ctx, &Room{}, &Room{RoomId: roomId})
+	if before == nil {
+		return &RoomNotFoundError{}
+	}
+	after := *before
+	after.Name = body.Name
+	after.Time = &body.Time
+	after.EndTime = body.EndTime
+	after.Capacity = body.Capacity
+	after.Location = body.Location
+	if body.Available != nil {
+		after.Available = body.Available
+	}
+	// 保留的座位随时可能被确认，也需要计入
+	if after.Capacity < after.Occupancy+after.Held {
+		return &validate.FieldError{Fields: map[string]string{
+			"capacity": "容量不能小于已选择与已保留的人数",
+		}}
+	}
+	err := validateRoom(ctx, &after)
+	if err != nil {
+		return err
+	}
+	srv := service.GetService()
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Room{}).Where(&Room{RoomId: roomId}).
+			Select("name", "time", "end_time", "capacity", "location", "available").Updates(&after).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "room.update", AuditTargetRoom, roomId, before, &after)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	// 容量变化也会影响空位数量
+	publishRoomOccupancy(ctx, roomId)
+	return nil
+}
+
+// validateRoom 检查房间的时间是否有效，以及是否与同一地点的其他可用房间重叠。
+// 不可用的房间不参与重叠检查，重新设为可用时会再次检查。
+//
+// ctx 是上下文。
+// room 是待保存的房间。
+func validateRoom(ctx context.Context, room *Room) error {
+	if room.EndTime != nil && !room.EndTime.After(*room.Time) {
+		return &validate.FieldError{Fields: map[string]string{
+			"end_time": "结束时间需要晚于开始时间",
+		}}
+	}
+	if room.Available == nil || !*room.Available {
+		return nil
+	}
+	srv := service.GetService()
+	var rooms []Room
+	err := srv.DB.WithContext(ctx).Model(&Room{}).Where(&Room{
+		Location:  room.Location,
+		Available: &[]bool{true}[0],
+	}).Where("room_id <> ? AND time IS NOT NULL", room.RoomId).Find(&rooms).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	start, end := *room.Time, *room.GetEnd()
+	for _, v := range rooms {
+		if v.Time.Before(end) && start.Before(*v.GetEnd()) {
+			slog.Debug("model.validateRoom: 房间时间重叠", "roomId", room.RoomId, "other", v.RoomId)
+			return &RoomOverlapError{Name: v.Name}
+		}
+	}
+	return nil
+}
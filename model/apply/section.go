@@ -0,0 +1,219 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/markdown"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"log/slog"
+)
+
+// Section 是文本表单中问题的分区。
+type Section struct {
+	gorm.Model
+	// SectionId 是分区的唯一标识符。
+	SectionId string `gorm:"type:varchar(36);uniqueIndex"`
+	// Name 是分区的名称。
+	Name string `gorm:"type:varchar(255)"`
+	// Description 是分区的描述，使用Markdown编写。
+	Description string `gorm:"type:text"`
+	// Sequence 是分区的顺序，数值越小越靠前。
+	Sequence int `gorm:"type:int;default:0"`
+}
+
+// SectionBody 是管理员创建或更新分区的请求。
+type SectionBody struct {
+	// Id 是分区的唯一标识符，创建时为空则自动生成。
+	Id string `json:"id" binding:"omitempty,max=36"`
+	// Name 是分区的名称。
+	Name string `json:"name" binding:"required,max=255"`
+	// Description 是分区的描述，使用Markdown编写。
+	Description string `json:"description"`
+	// Sequence 是分区的顺序。
+	Sequence int `json:"sequence"`
+}
+
+type SectionRequestUri struct {
+	// Id 是分区的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+}
+
+// SectionListItem 是分区列表项。
+type SectionListItem struct {
+	// Id 是分区的唯一标识符。
+	Id string `json:"id"`
+	// Name 是分区的名称。
+	Name string `json:"name"`
+	// Description 是分区描述的Markdown原文。
+	Description string `json:"description"`
+	// DescriptionHtml 是分区描述渲染后的安全HTML。
+	DescriptionHtml string `json:"description_html"`
+	// Sequence 是分区的顺序。
+	Sequence int `json:"sequence"`
+}
+
+// GetSectionListResponse 是获取分区列表的响应。
+type GetSectionListResponse struct {
+	Sections []SectionListItem `json:"sections"`
+}
+
+type SectionNotFoundError struct{}
+
+func (e *SectionNotFoundError) Error() string {
+	return "分区不存在"
+}
+
+type DuplicateSectionError struct{}
+
+func (e *DuplicateSectionError) Error() string {
+	return "分区ID已存在"
+}
+
+// GetSectionList 获取按顺序排列的分区列表。
+//
+// ctx 是上下文。
+func GetSectionList(ctx context.Context) *GetSectionListResponse {
+	slog.Debug("model.GetSectionList: 正在获取分区列表")
+	srv := service.GetService()
+	var sections []Section
+	err := srv.DB.WithContext(ctx).Model(&Section{}).Order("sequence, id").Find(&sections).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetSectionListResponse{Sections: make([]SectionListItem, 0, len(sections))}
+	for _, v := range sections {
+		result.Sections = append(result.Sections, SectionListItem{
+			Id:              v.SectionId,
+			Name:            v.Name,
+			Description:     v.Description,
+			DescriptionHtml: markdown.Render(v.Description),
+			Sequence:        v.Sequence,
+		})
+	}
+	return &result
+}
+
+// CheckIsSectionExists 检查分区是否存在。
+//
+// ctx 是上下文。
+// sectionId 是分区的唯一标识符。
+func CheckIsSectionExists(ctx context.Context, sectionId string) bool {
+	slog.Debug("model.CheckIsSectionExists: 正在检查分区是否存在", "sectionId", sectionId)
+	srv := service.GetService()
+	var section Section
+	err := srv.DB.WithContext(ctx).Model(&Section{}).Where(&Section{SectionId: sectionId}).First(&section).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return true
+}
+
+// CreateSection 创建分区，并返回分区的唯一标识符。
+// 指定的唯一标识符已被使用（包括已删除的分区）时返回*DuplicateSectionError。
+//
+// ctx 是上下文。
+// body 是分区内容。
+func CreateSection(ctx context.Context, body *SectionBody) (string, error) {
+	slog.Debug("model.CreateSection: 正在创建分区", "name", body.Name)
+	srv := service.GetService()
+	sectionId := body.Id
+	if sectionId == "" {
+		sectionId = uuid.NewString()
+	}
+	section := Section{
+		SectionId:   sectionId,
+		Name:        body.Name,
+		Description: body.Description,
+		Sequence:    body.Sequence,
+	}
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Unscoped().Model(&Section{}).Where(&Section{SectionId: sectionId}).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return &DuplicateSectionError{}
+		}
+		err = tx.Create(&section).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "section.create", AuditTargetSection, sectionId, nil, &section)
+	})
+	if err != nil {
+		if v, ok := err.(*DuplicateSectionError); ok {
+			return "", v
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return sectionId, nil
+}
+
+// UpdateSection 更新分区。
+//
+// ctx 是上下文。
+// sectionId 是分区的唯一标识符。
+// body 是分区内容。
+func UpdateSection(ctx context.Context, sectionId string, body *SectionBody) error {
+	slog.Debug("model.UpdateSection: 正在更新分区", "sectionId", sectionId)
+	before := findAuditSnapshot(ctx, &Section{}, &Section{SectionId: sectionId})
+	if before == nil {
+		return &SectionNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		after := *before
+		after.Name = body.Name
+		after.Description = body.Description
+		after.Sequence = body.Sequence
+		err := tx.Model(&Section{}).Where(&Section{SectionId: sectionId}).
+			Select("name", "description", "sequence").Updates(&after).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "section.update", AuditTargetSection, sectionId, before, &after)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// DeleteSection 删除分区，分区下的问题将不再属于任何分区。
+//
+// ctx 是上下文。
+// sectionId 是分区的唯一标识符。
+func DeleteSection(ctx context.Context, sectionId string) error {
+	slog.Debug("model.DeleteSection: 正在删除分区", "sectionId", sectionId)
+	before := findAuditSnapshot(ctx, &Section{}, &Section{SectionId: sectionId})
+	if before == nil {
+		return &SectionNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&Section{SectionId: sectionId}).Delete(&Section{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Question{}).Where(&Question{SectionId: sectionId}).Update("section_id", "").Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "section.delete", AuditTargetSection, sectionId, before, nil)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
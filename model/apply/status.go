@@ -10,6 +10,12 @@ type GetStatusResponse struct {
 	RoomSelection bool `json:"room_selection"`
 	// TextForm 是用户是否已经填写文本表单。
 	TextForm bool `json:"textform"`
+	// Offer 是用户被录取的组别ID，尚未录取时为空。
+	Offer string `json:"offer"`
+	// Withdrawn 是用户是否已经撤回申请。
+	Withdrawn bool `json:"withdrawn"`
+	// CanReapply 是撤回后是否还可以重新申请。
+	CanReapply bool `json:"can_reapply"`
 }
 
 // GetStatus 获取用户的状态。
@@ -17,9 +23,15 @@ type GetStatusResponse struct {
 // ctx 是上下文。
 // openid 是用户的Openid。
 func GetStatus(ctx context.Context, openid string) *GetStatusResponse {
-	return &GetStatusResponse{
+	result := &GetStatusResponse{
 		Ticket:        CheckIsTicketExists(ctx, openid),
 		RoomSelection: CheckIsSelectionExists(ctx, openid),
 		TextForm:      CheckIsTextFormSubmitted(ctx, openid),
 	}
+	if ticket := findTicket(ctx, openid); ticket != nil {
+		result.Offer = ticket.OfferGroup
+		result.Withdrawn = ticket.Withdrawn != nil && *ticket.Withdrawn
+		result.CanReapply = result.Withdrawn && !IsApplicationClosed()
+	}
+	return result
 }
@@ -3,38 +3,51 @@ package apply
 import (
 	"context"
 	"elab-backend/service"
+	"elab-backend/util/markdown"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log/slog"
+	"sort"
 )
 
 // TextForm 是用户的文字表单。
 type TextForm struct {
 	gorm.Model
 	// OpenId 是用户的OpenId。
-	OpenId string `gorm:"type:varchar(40)"`
+	OpenId string `gorm:"type:varchar(40);uniqueIndex:idx_text_form_question"`
 	// Question 是用户需要回答的问题。
-	QuestionId string `gorm:"type:varchar(1024)"`
+	QuestionId string `gorm:"type:varchar(36);uniqueIndex:idx_text_form_question"`
 	// Answer 是用户的回答。
 	Answer string `gorm:"type:varchar(1024)"`
 	// Submitted 是用户是否已经提交申请表。
 	Submitted *bool `gorm:"type:bool"`
+	// Retired 是对应的问题是否已被移除，移除后的回答保留但不再展示。
+	Retired *bool `gorm:"type:bool;default:false"`
 }
 
 // Question 是用户需要回答的问题列表
 type Question struct {
 	gorm.Model
 	// QuestionId 是用户需要回答的问题ID。
-	QuestionId string `gorm:"type:varchar(36)"`
+	QuestionId string `gorm:"type:varchar(36);uniqueIndex"`
 	// Question 是问题标题。
 	Question string `gorm:"type:varchar(1024)"`
-	// Text 是问题的文字描述。
-	Text string `gorm:"type:varchar(1024)"`
+	// Text 是问题的文字描述，使用Markdown编写。
+	Text string `gorm:"type:text"`
+	// SectionId 是问题所属分区的唯一标识符，为空时不属于任何分区。
+	SectionId string `gorm:"type:varchar(36)"`
+	// Sequence 是问题在所属分区中的顺序，数值越小越靠前。
+	Sequence int `gorm:"type:int;default:0"`
+	// VisibleWhen 是问题的显示条件，为空时总是显示。
+	VisibleWhen *QuestionVisibility `gorm:"type:text;serializer:json"`
 }
 
 // GetQuestionListResponse 获取用户的文字表单列表。
 type GetQuestionListResponse struct {
-	// QuestionList 是用户需要回答的问题列表。
+	// Sections 是按顺序排列的分区列表。
+	Sections []SectionListItem `json:"sections"`
+	// QuestionList 是用户需要回答的问题列表，按分区和顺序排列。
 	Questions []QuestionListItem `json:"questions"`
 }
 
@@ -49,8 +62,14 @@ type QuestionListItem struct {
 	Id string `json:"id"`
 	// Question 是问题标题。
 	Question string `json:"question"`
-	// Text 是问题的文字描述。
+	// Text 是问题描述的Markdown原文。
 	Text string `json:"text"`
+	// TextHtml 是问题描述渲染后的安全HTML。
+	TextHtml string `json:"text_html"`
+	// SectionId 是问题所属分区的唯一标识符。
+	SectionId string `json:"section_id"`
+	// Sequence 是问题在所属分区中的顺序。
+	Sequence int `json:"sequence"`
 	// Submitted 是用户是否已经提交申请表。
 	Submitted bool `json:"submitted"`
 }
@@ -82,20 +101,33 @@ type TextFormListItem struct {
 	Submitted bool `json:"submitted"`
 }
 
-// GetQuestionList 获取问题列表。
+// GetQuestionList 获取用户可见的问题列表。
+// 仅限特定组别的问题只对志愿中包含这些组别的用户可见，
+// 带有显示条件的问题只在条件满足时可见，隐藏的问题不要求回答。
+// 问题先按所属分区的顺序排列，不属于任何分区的问题排在最前，
+// 同一分区内再按问题自身的顺序排列。
 //
 // ctx 是上下文。
 func GetQuestionList(ctx context.Context, openid string) *GetQuestionListResponse {
 	slog.Debug("model.GetQuestionList: 正在获取问题列表")
-	srv := service.GetService()
-	var questions []Question
-	err := srv.DB.WithContext(ctx).Model(&Question{}).Find(&questions).Error
-	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
+	questions := getVisibleQuestionList(ctx, openid)
+	sections := GetSectionList(ctx).Sections
+	sectionRank := make(map[string]int, len(sections))
+	for i, v := range sections {
+		sectionRank[v.Id] = i
+	}
+	rankOf := func(q Question) int {
+		rank, ok := sectionRank[q.SectionId]
+		if !ok {
+			return -1
+		}
+		return rank
 	}
+	sort.SliceStable(questions, func(i, j int) bool {
+		return rankOf(questions[i]) < rankOf(questions[j])
+	})
 	textFormList := GetTextForm(ctx, openid)
-	var result GetQuestionListResponse
+	result := GetQuestionListResponse{Sections: sections}
 	for _, v := range questions {
 		var submitted bool
 		for _, vv := range textFormList.TextForms {
@@ -103,24 +135,28 @@ func GetQuestionList(ctx context.Context, openid string) *GetQuestionListRespons
 				submitted = vv.Submitted
 			}
 		}
-		result.Questions = append(result.Questions, QuestionListItem{
-			Id:        v.QuestionId,
-			Question:  v.Question,
-			Text:      v.Text,
-			Submitted: submitted,
-		})
+		result.Questions = append(result.Questions, newQuestionListItem(&v, submitted))
 	}
 	return &result
 }
 
-func GetQuestion(ctx context.Context, openid string, questionId string) *QuestionListItem {
-	slog.Debug("model.GetQuestion: 正在获取问题")
-	srv := service.GetService()
-	var question Question
-	err := srv.DB.WithContext(ctx).Model(&Question{}).Where(&Question{QuestionId: questionId}).Find(&question).Error
-	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
+// GetQuestion 获取用户可见的单个问题，可见性与GetQuestionList相同。
+// 问题不存在或对用户不可见时返回*QuestionNotFoundError。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// questionId 是问题ID。
+func GetQuestion(ctx context.Context, openid string, questionId string) (*QuestionListItem, error) {
+	slog.Debug("model.GetQuestion: 正在获取问题", "openid", openid, "questionId", questionId)
+	var question *Question
+	for _, v := range getVisibleQuestionList(ctx, openid) {
+		if v.QuestionId == questionId {
+			question = &v
+			break
+		}
+	}
+	if question == nil {
+		return nil, &QuestionNotFoundError{}
 	}
 	textFormList := GetTextForm(ctx, openid)
 	var submitted bool
@@ -129,10 +165,69 @@ func GetQuestion(ctx context.Context, openid string, questionId string) *Questio
 			submitted = vv.Submitted
 		}
 	}
-	return &QuestionListItem{
+	item := newQuestionListItem(question, submitted)
+	return &item, nil
+}
+
+// getVisibleQuestionList 获取对用户可见的问题列表，按问题自身的顺序排列。
+// 问题需要面向用户的任一志愿组别，且满足其显示条件。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func getVisibleQuestionList(ctx context.Context, openid string) []Question {
+	srv := service.GetService()
+	questions, err := findVisibleQuestions(ctx, srv.DB.WithContext(ctx), openid)
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return questions
+}
+
+// findVisibleQuestions 与getVisibleQuestionList相同，但问题和回答从给定的连接读取，
+// 在事务中调用时显示条件会使用事务中尚未提交的回答。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+func findVisibleQuestions(ctx context.Context, tx *gorm.DB, openid string) ([]Question, error) {
+	var questions []Question
+	err := tx.Model(&Question{}).Order("sequence, id").Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
+	ticket := findTicket(ctx, openid)
+	groups := GetTicketGroups(ctx, openid)
+	restrictions := GetGroupRestrictionMap(ctx, RestrictionTypeQuestion)
+	candidates := make([]Question, 0, len(questions))
+	for _, v := range questions {
+		if isAllowedForGroups(restrictions[v.QuestionId], groups) {
+			candidates = append(candidates, v)
+		}
+	}
+	var textForms []TextForm
+	err = tx.Model(&TextForm{}).Where(&TextForm{OpenId: openid}).Find(&textForms).Error
+	if err != nil {
+		return nil, err
+	}
+	answers := make(map[string]string, len(textForms))
+	for _, v := range textForms {
+		answers[v.QuestionId] = v.Answer
+	}
+	return filterByCondition(candidates, &conditionEnv{
+		ticket:  ticket,
+		groups:  groups,
+		answers: answers,
+	}), nil
+}
+
+func newQuestionListItem(question *Question, submitted bool) QuestionListItem {
+	return QuestionListItem{
 		Id:        question.QuestionId,
 		Question:  question.Question,
 		Text:      question.Text,
+		TextHtml:  markdown.Render(question.Text),
+		SectionId: question.SectionId,
+		Sequence:  question.Sequence,
 		Submitted: submitted,
 	}
 }
@@ -143,14 +238,13 @@ func GetQuestion(ctx context.Context, openid string, questionId string) *Questio
 // openid 是用户的Openid。
 func GetTextForm(ctx context.Context, openid string) *GetTextFormListResponse {
 	slog.Debug("model.GetTextForm: 正在获取文本表单", "openid", openid)
-	isTextFormExists := CheckIsTextFormExists(ctx, openid)
-	if !isTextFormExists {
-		slog.Debug("model.GetTextForm: 文本表单不存在，正在初始化", "openid", openid)
-		InitTextForm(ctx, openid)
-	}
+	SyncTextForm(ctx, openid)
 	srv := service.GetService()
 	var textForms []TextForm
-	err := srv.DB.WithContext(ctx).Model(&TextForm{}).Where(&TextForm{OpenId: openid}).Find(&textForms).Error
+	err := srv.DB.WithContext(ctx).Model(&TextForm{}).Where(&TextForm{
+		OpenId:  openid,
+		Retired: &[]bool{false}[0],
+	}).Find(&textForms).Error
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
@@ -168,49 +262,160 @@ func GetTextForm(ctx context.Context, openid string) *GetTextFormListResponse {
 	return &result
 }
 
-func InitTextForm(ctx context.Context, openid string) {
-	slog.Debug("model.InitTextForm: 正在初始化文本表单", "openid", openid)
+// SyncTextForm 将用户的文本表单与当前对其可见的问题列表同步。
+// 新增的问题会以未回答的状态加入，已移除或因组别不再可见的问题的回答会被隐藏但保留，
+// 重新启用的问题会恢复之前的回答。并发同步时重复添加的问题会被忽略。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func SyncTextForm(ctx context.Context, openid string) {
+	slog.Debug("model.SyncTextForm: 正在同步文本表单", "openid", openid)
 	srv := service.GetService()
-	var questions []Question
-	err := srv.DB.WithContext(ctx).Model(&Question{}).Find(&questions).Error
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return syncTextForm(ctx, tx, openid)
+	})
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
+}
+
+// syncTextForm 在事务中同步用户的文本表单，见SyncTextForm。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+func syncTextForm(ctx context.Context, tx *gorm.DB, openid string) error {
+	questions, err := findVisibleQuestions(ctx, tx, openid)
+	if err != nil {
+		return err
+	}
+	var textForms []TextForm
+	err = tx.Model(&TextForm{}).Where(&TextForm{OpenId: openid}).Find(&textForms).Error
+	if err != nil {
+		return err
+	}
+	current := make(map[string]struct{}, len(questions))
 	for _, v := range questions {
-		slog.Debug("model.InitTextForm: 正在初始化文本表单", "openid", openid, "questionId", v.QuestionId)
-		err := srv.DB.WithContext(ctx).Model(&TextForm{}).Create(
-			&TextForm{OpenId: openid, QuestionId: v.QuestionId, Submitted: &[]bool{false}[0]}).Error
+		current[v.QuestionId] = struct{}{}
+	}
+	existing := make(map[string]struct{}, len(textForms))
+	for _, v := range textForms {
+		existing[v.QuestionId] = struct{}{}
+		_, isCurrent := current[v.QuestionId]
+		isRetired := v.Retired != nil && *v.Retired
+		if isCurrent == !isRetired {
+			continue
+		}
+		slog.Debug("model.SyncTextForm: 正在更新问题状态", "openid", openid, "questionId", v.QuestionId, "retired", !isCurrent)
+		err := tx.Model(&TextForm{}).Where("id = ?", v.ID).Update("retired", !isCurrent).Error
 		if err != nil {
-			slog.Error("调用ORM失败。", "error", err)
-			panic(err)
+			return err
+		}
+	}
+	for _, v := range questions {
+		if _, ok := existing[v.QuestionId]; ok {
+			continue
+		}
+		slog.Debug("model.SyncTextForm: 正在添加新问题", "openid", openid, "questionId", v.QuestionId)
+		err := tx.Model(&TextForm{}).Clauses(clause.OnConflict{DoNothing: true}).Create(&TextForm{
+			OpenId:     openid,
+			QuestionId: v.QuestionId,
+			Submitted:  &[]bool{false}[0],
+			Retired:    &[]bool{false}[0],
+		}).Error
+		if err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // UpdateTextForm 更新用户的文本表单。
+// 用户没有该问题的表单项（问题不存在、已停用或对用户隐藏）时返回*QuestionNotFoundError，不会记录修订。
 //
 // ctx 是上下文。
 // openid 是用户的Openid。
 // request 是用户的请求。
-func UpdateTextForm(ctx context.Context, openid string, request *UpdateTextFormRequest) {
+func UpdateTextForm(ctx context.Context, openid string, request *UpdateTextFormRequest) error {
 	slog.Debug("model.UpdateTextForm: 正在更新文本表单", "openid", openid, "questionId", request.Id)
 	srv := service.GetService()
-	err := srv.DB.WithContext(ctx).Model(&TextForm{}).Where(&TextForm{
-		OpenId:     openid,
-		QuestionId: request.Id,
-	}).Updates(&TextForm{
-		Answer:    request.Answer,
-		Submitted: &[]bool{true}[0],
-	}).Error
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := syncTextForm(ctx, tx, openid)
+		if err != nil {
+			return err
+		}
+		pending, err := countPendingAnswers(ctx, tx, openid)
+		if err != nil {
+			return err
+		}
+		condition := TextForm{
+			OpenId:     openid,
+			QuestionId: request.Id,
+			Retired:    &[]bool{false}[0],
+		}
+		// 回答未变化时MySQL报告的影响行数为0，因此先确认表单项存在，而不是依赖RowsAffected
+		var count int64
+		err = tx.Model(&TextForm{}).Where(&condition).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return &QuestionNotFoundError{}
+		}
+		err = tx.Model(&TextForm{}).Where(&condition).Updates(&TextForm{
+			Answer:    request.Answer,
+			Submitted: &[]bool{true}[0],
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = createRevision(ctx, tx, openid, RevisionTypeTextForm, request.Id, request.Answer)
+		if err != nil {
+			return err
+		}
+		// 新的回答可能使其他问题满足显示条件，需要重新同步后再判断是否已全部回答
+		err = syncTextForm(ctx, tx, openid)
+		if err != nil {
+			return err
+		}
+		remaining, err := countPendingAnswers(ctx, tx, openid)
+		if err != nil {
+			return err
+		}
+		if pending > 0 && remaining == 0 {
+			return enqueueEvent(ctx, tx, OutboxEventStatusChanged, openid, &StatusChangedPayload{
+				OpenId: openid,
+				Status: ApplicationStatusTextFormCompleted,
+			})
+		}
+		return nil
+	})
 	if err != nil {
+		if v, ok := err.(*QuestionNotFoundError); ok {
+			return v
+		}
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
 	slog.Debug("model.UpdateTextForm: 更新文本表单成功", "openid", openid)
+	return nil
+}
+
+// countPendingAnswers 在事务中统计用户尚未回答的问题数量，不会同步文本表单。
+//
+// tx 是当前事务。
+// openid 是用户的Openid。
+func countPendingAnswers(ctx context.Context, tx *gorm.DB, openid string) (int64, error) {
+	var count int64
+	err := tx.WithContext(ctx).Model(&TextForm{}).Where(&TextForm{
+		OpenId:    openid,
+		Submitted: &[]bool{false}[0],
+		Retired:   &[]bool{false}[0],
+	}).Count(&count).Error
+	return count, err
 }
 
-// CheckIsTextFormSubmitted 检查用户是否已经填写了文本表单。
+// CheckIsTextFormSubmitted 检查用户是否已经填写了文本表单，只读取数据，不会同步文本表单。
 //
 // ctx 是上下文。
 // openid 是用户的Openid。
@@ -230,16 +435,24 @@ func CheckIsTextFormSubmitted(ctx context.Context, openid string) bool {
 		slog.Debug("model.CheckIsTextFormSubmitted: 没有openid为", "openid", openid, "的文本表单")
 		return false
 	}
-	var counts int64
+	// 问题可能在中途增减，按当前可见的问题计算，已隐藏的回答在问题重新可见时会被恢复，因此同样计入
+	var answered []string
 	err = srv.DB.WithContext(ctx).Model(&TextForm{}).Where(&TextForm{
 		OpenId:    openid,
-		Submitted: &[]bool{false}[0],
-	}).Count(&counts).Error
+		Submitted: &[]bool{true}[0],
+	}).Pluck("question_id", &answered).Error
 	if err != nil {
-		isNotExist := errors.Is(err, gorm.ErrRecordNotFound)
-		if !isNotExist {
-			slog.Error("调用ORM失败。", "error", err)
-			panic(err)
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	submitted := make(map[string]struct{}, len(answered))
+	for _, v := range answered {
+		submitted[v] = struct{}{}
+	}
+	counts := 0
+	for _, v := range getVisibleQuestionList(ctx, openid) {
+		if _, ok := submitted[v.QuestionId]; !ok {
+			counts++
 		}
 	}
 	if counts == 0 {
@@ -3,22 +3,53 @@ package apply
 import (
 	"context"
 	"elab-backend/service"
+	"elab-backend/util/crypto"
+	"elab-backend/util/validate"
+	"encoding/json"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"log/slog"
+	"strings"
+	"time"
 )
 
 type TicketBody struct {
 	// Name 是用户的姓名。
-	Name string `json:"name"`
+	Name string `json:"name" binding:"required,max=36"`
 	// StudentId 是用户的学号。
-	StudentId string `json:"student_id"`
+	StudentId string `json:"student_id" binding:"required,max=16,student_id"`
 	// ClassName 是用户的班级，以此来替代所属学院
-	ClassName string `json:"class_name"`
-	// Group 是用户的组别，如“软件组”、“硬件组”等。
-	Group string `json:"group"`
-	// Contact 是用户的联系方式，如手机号等。
-	Contact string `json:"contact"`
+	ClassName string `json:"class_name" binding:"required,max=16"`
+	// Group 是用户的第一志愿组别ID，需要是已有的组别。
+	Group string `json:"group" binding:"required,max=36"`
+	// Preferences 是按顺位排列的志愿组别ID，第一项与Group相同。
+	// 只填写Group时视为只有一个志愿。
+	Preferences []string `json:"preferences" binding:"omitempty,max=8,unique,dive,required,max=36"`
+	// Contact 是用户的联系方式，为手机号或邮箱。
+	Contact string `json:"contact" binding:"required,max=64,contact"`
+}
+
+// Normalize 去除各字段首尾的空白，并使Group与第一志愿保持一致。
+func (body *TicketBody) Normalize() {
+	body.Name = strings.TrimSpace(body.Name)
+	body.StudentId = strings.TrimSpace(body.StudentId)
+	body.ClassName = strings.TrimSpace(body.ClassName)
+	body.Group = strings.TrimSpace(body.Group)
+	body.Contact = strings.TrimSpace(body.Contact)
+	preferences := make([]string, 0, len(body.Preferences))
+	for _, v := range body.Preferences {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			preferences = append(preferences, v)
+		}
+	}
+	if len(preferences) == 0 && body.Group != "" {
+		preferences = append(preferences, body.Group)
+	}
+	if len(preferences) > 0 {
+		body.Group = preferences[0]
+	}
+	body.Preferences = preferences
 }
 
 // Ticket 是科中成员的申请表，用于装填基本信息。
@@ -26,18 +57,40 @@ type Ticket struct {
 	gorm.Model
 	// Openid 是用户的Openid。
 	OpenId string `gorm:"type:varchar(40)"`
-	// Name 是用户的姓名。
-	Name string `gorm:"type:varchar(36)"`
-	// StudentId 是用户的学号。
-	StudentId string `gorm:"type:varchar(16)"`
+	// Name 是用户的姓名，加密保存。
+	Name string `gorm:"type:varchar(255);serializer:encrypted"`
+	// StudentId 是用户的学号，加密保存，查询时使用StudentIdIndex。
+	StudentId string `gorm:"type:varchar(255);serializer:encrypted"`
+	// StudentIdIndex 是学号的盲索引，见crypto.BlindIndex。
+	StudentIdIndex string `gorm:"type:varchar(64);index"`
 	// ClassName 是用户的班级，以此来替代所属学院
 	ClassName string `gorm:"type:varchar(16)"`
-	// Group 是用户的组别，如“软件组”、“硬件组”等。
-	Group string `gorm:"type:varchar(16)"`
-	// Contact 是用户的联系方式，如手机号等。
-	Contact string `gorm:"type:varchar(16)"`
+	// Group 是用户的组别ID，对应Group.GroupId，如“软件组”、“硬件组”等。
+	Group string `gorm:"type:varchar(36)"`
+	// Contact 是用户的联系方式，为手机号或邮箱，加密保存。
+	Contact string `gorm:"type:varchar(255);serializer:encrypted"`
 	// Submitted 是用户是否已经提交申请表。
 	Submitted *bool `gorm:"type:bool"`
+	// Campaign 是申请表所属的招新批次。
+	Campaign string `gorm:"type:varchar(32);index"`
+	// OfferGroup 是最终录取的组别ID，为空表示尚未录取。
+	OfferGroup string `gorm:"type:varchar(36)"`
+	// OfferedAt 是录取的时间。
+	OfferedAt *time.Time `gorm:"type:datetime"`
+	// Withdrawn 是申请是否已被用户撤回，撤回的申请会保留用于统计。
+	Withdrawn *bool `gorm:"type:bool;default:false"`
+	// WithdrawnAt 是最近一次撤回的时间。
+	WithdrawnAt *time.Time `gorm:"type:datetime"`
+	// WithdrawReason 是最近一次撤回时填写的原因。
+	WithdrawReason string `gorm:"type:varchar(255)"`
+	// AnonymizedAt 是个人信息因超过保留期限而被清除的时间，见RunRetentionPolicy。
+	AnonymizedAt *time.Time `gorm:"type:datetime"`
+}
+
+type DuplicateApplicationError struct{}
+
+func (e *DuplicateApplicationError) Error() string {
+	return "该学号已在其他账号提交过申请，如有疑问请联系工作人员"
 }
 
 // GetTicket 获取用户的申请表。
@@ -47,7 +100,8 @@ type Ticket struct {
 func GetTicket(ctx context.Context, openid string) *TicketBody {
 	slog.Debug("model.GetTicket: 正在获取申请表", "openid", openid)
 	slog.Debug("model.GetTicket: 正在检查申请表存在性", "openid", openid)
-	if !CheckIsTicketExists(ctx, openid) {
+	// 未提交或已撤回的申请表也会复用，不能用CheckIsTicketExists判断，否则每次获取都会新建一行
+	if findTicket(ctx, openid) == nil {
 		slog.Debug("model.GetTicket: 申请表不存在，正在创建", "openid", openid)
 		InitTicket(ctx, openid)
 	}
@@ -62,11 +116,12 @@ func GetTicket(ctx context.Context, openid string) *TicketBody {
 		panic(err)
 	}
 	return &TicketBody{
-		Name:      ticket.Name,
-		StudentId: ticket.StudentId,
-		ClassName: ticket.ClassName,
-		Group:     ticket.Group,
-		Contact:   ticket.Contact,
+		Name:        ticket.Name,
+		StudentId:   ticket.StudentId,
+		ClassName:   ticket.ClassName,
+		Group:       ticket.Group,
+		Contact:     ticket.Contact,
+		Preferences: GetTicketGroups(ctx, openid),
 	}
 }
 
@@ -105,6 +160,7 @@ func InitTicket(ctx context.Context, openid string) {
 	ticket := Ticket{
 		OpenId:    openid,
 		Submitted: &[]bool{false}[0],
+		Campaign:  GetCampaign(),
 	}
 	err := srv.DB.WithContext(ctx).Model(&Ticket{}).Create(&ticket).Error
 	if err != nil {
@@ -113,26 +169,133 @@ func InitTicket(ctx context.Context, openid string) {
 	}
 }
 
-// UpdateTicket 更新用户的申请表。
+// UpdateTicket 校验并更新用户的申请表。
+// 校验失败时返回*validate.FieldError，学号在本批次中已被其他账号提交时返回*DuplicateApplicationError，
+// 已撤回的申请在截止后重新提交时返回*ApplicationClosedError，用户尚未获取过申请表时返回*TicketNotFoundError，
+// 这些情况都不会写入任何数据。
+// 调用方需要持有该学号的锁，见GetStudentIdLockKey。
 //
 // openid 是用户的Openid。
-func UpdateTicket(ctx context.Context, openid string, body *TicketBody) {
+func UpdateTicket(ctx context.Context, openid string, body *TicketBody) error {
 	slog.Debug("model.UpdateTicket: 正在更新申请表", "openid", openid)
+	body.Normalize()
+	err := validate.Struct(body)
+	if err != nil {
+		slog.Debug("model.UpdateTicket: 申请表校验失败", "openid", openid, "error", err)
+		return err
+	}
+	if !CheckIsGroupListExists(ctx, body.Preferences) {
+		slog.Debug("model.UpdateTicket: 组别不存在", "openid", openid, "preferences", body.Preferences)
+		return &validate.FieldError{Fields: map[string]string{
+			"preferences": "组别不存在",
+		}}
+	}
+	if CheckIsStudentIdTaken(ctx, openid, body.StudentId) {
+		slog.Debug("model.UpdateTicket: 学号已被其他账号使用", "openid", openid)
+		return &DuplicateApplicationError{}
+	}
+	existing := findTicket(ctx, openid)
+	if existing == nil {
+		// 申请表由GetTicket创建，此时更新不会影响任何行，不能记录修订或通知提交
+		slog.Debug("model.UpdateTicket: 申请表不存在", "openid", openid)
+		return &TicketNotFoundError{}
+	}
+	if existing.Withdrawn != nil && *existing.Withdrawn && IsApplicationClosed() {
+		slog.Debug("model.UpdateTicket: 申请已撤回且已截止", "openid", openid)
+		return &ApplicationClosedError{}
+	}
 	srv := service.GetService()
 	ticket := Ticket{
-		OpenId:    openid,
-		Name:      body.Name,
-		StudentId: body.StudentId,
-		ClassName: body.ClassName,
-		Group:     body.Group,
-		Contact:   body.Contact,
-		Submitted: &[]bool{true}[0],
+		OpenId:         openid,
+		Name:           body.Name,
+		StudentId:      body.StudentId,
+		StudentIdIndex: crypto.BlindIndex(body.StudentId),
+		ClassName:      body.ClassName,
+		Group:          body.Group,
+		Contact:        body.Contact,
+		Submitted:      &[]bool{true}[0],
+		Campaign:       GetCampaign(),
+		Withdrawn:      &[]bool{false}[0],
+	}
+	value, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("model.UpdateTicket: 无法序列化申请表", "error", err)
+		panic(err)
+	}
+	err = srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Ticket{}).Where(&Ticket{
+			OpenId: openid,
+		}).Updates(&ticket).Error
+		if err != nil {
+			return err
+		}
+		err = setTicketPreferences(ctx, tx, openid, body.Preferences)
+		if err != nil {
+			return err
+		}
+		err = createRevision(ctx, tx, openid, RevisionTypeTicket, "", string(value))
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, OutboxEventTicketSubmitted, openid, &TicketSubmittedPayload{
+			OpenId:      openid,
+			Group:       body.Group,
+			Preferences: body.Preferences,
+			First:       existing.Submitted == nil || !*existing.Submitted,
+		})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
 	}
+	return nil
+}
+
+// CheckIsStudentIdTaken 检查学号在当前批次中是否已被其他账号提交。
+//
+// ctx 是上下文。
+// openid 是当前用户的Openid。
+// studentId 是学号。
+func CheckIsStudentIdTaken(ctx context.Context, openid string, studentId string) bool {
+	slog.Debug("model.CheckIsStudentIdTaken: 正在检查学号是否已被使用", "openid", openid)
+	srv := service.GetService()
+	var count int64
+	err := srv.DB.WithContext(ctx).Model(&Ticket{}).Where(&Ticket{
+		StudentIdIndex: crypto.BlindIndex(studentId),
+		Submitted:      &[]bool{true}[0],
+		Campaign:       GetCampaign(),
+	}).Where("open_id <> ?", openid).Count(&count).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return count > 0
+}
+
+// GetStudentIdLockKey 获取学号对应的锁的键，用于防止两个账号同时提交相同的学号。
+//
+// studentId 是学号。
+func GetStudentIdLockKey(studentId string) string {
+	// 使用盲索引，避免在Redis中留下明文学号
+	return "ticket_student_id:" + GetCampaign() + ":" + crypto.BlindIndex(strings.TrimSpace(studentId))
+}
+
+// findTicket 获取用户的申请表记录，不存在时返回nil，且不会初始化申请表。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func findTicket(ctx context.Context, openid string) *Ticket {
+	srv := service.GetService()
+	var ticket Ticket
 	err := srv.DB.WithContext(ctx).Model(&Ticket{}).Where(&Ticket{
 		OpenId: openid,
-	}).Updates(&ticket).Error
+	}).First(&ticket).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
 	}
+	return &ticket
 }
@@ -0,0 +1,585 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"elab-backend/util/config"
+	"elab-backend/util/webhook"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// WebhookDeliveryStatusPending 表示等待投递，包括投递失败后等待重试。
+	WebhookDeliveryStatusPending = "pending"
+	// WebhookDeliveryStatusDelivered 表示接收方返回了2xx状态码。
+	WebhookDeliveryStatusDelivered = "delivered"
+	// WebhookDeliveryStatusFailed 表示重试次数用尽或端点已停用，可以手动重新投递。
+	WebhookDeliveryStatusFailed = "failed"
+)
+
+// WebhookEndpoint 是管理员登记的Webhook端点，订阅的事件产生后会向其发送签名后的POST请求。
+type WebhookEndpoint struct {
+	gorm.Model
+	// EndpointId 是端点的唯一标识符。
+	EndpointId string `gorm:"type:varchar(36);index"`
+	// Name 是端点的名称，如“飞书机器人”。
+	Name string `gorm:"type:varchar(255)"`
+	// Url 是接收请求的地址。
+	Url string `gorm:"type:varchar(512)"`
+	// Secret 是签名密钥，加密保存，不会写入审计记录。
+	Secret string `gorm:"type:varchar(255);serializer:encrypted" json:"-"`
+	// EventTypes 是订阅的事件类型，见OutboxEvent。
+	EventTypes []string `gorm:"type:text;serializer:json"`
+	// Enabled 是端点是否启用。
+	Enabled *bool `gorm:"type:bool;default:true"`
+}
+
+// WebhookDelivery 是一次向端点投递事件的记录，同一事件对同一端点只有一条记录。
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+	// EndpointId 是端点的唯一标识符。
+	EndpointId string `gorm:"type:varchar(36);uniqueIndex:idx_webhook_delivery_event"`
+	// EventId 是事件的唯一标识符，对应OutboxEvent.ID。
+	EventId uint `gorm:"uniqueIndex:idx_webhook_delivery_event"`
+	// EventType 是事件的类型。
+	EventType string `gorm:"type:varchar(64)"`
+	// Payload 是发送的请求正文，重新投递时发送相同的内容。
+	Payload string `gorm:"type:text"`
+	// Status 是投递状态。
+	Status string `gorm:"type:varchar(16);index"`
+	// Attempts 是已经尝试投递的次数。
+	Attempts int `gorm:"type:int;default:0"`
+	// NextAttemptAt 是下一次尝试投递的时间。
+	NextAttemptAt time.Time `gorm:"type:datetime;index"`
+	// ResponseStatus 是最近一次请求的响应状态码，请求未发出时为0。
+	ResponseStatus int `gorm:"type:int"`
+	// ResponseBody 是最近一次请求的响应正文的前1024个字节。
+	ResponseBody string `gorm:"type:text"`
+	// DurationMs 是最近一次请求的耗时，单位为毫秒。
+	DurationMs int64 `gorm:"type:bigint"`
+	// LastError 是最近一次投递失败的原因。
+	LastError string `gorm:"type:text"`
+	// DeliveredAt 是投递成功的时间。
+	DeliveredAt *time.Time `gorm:"type:datetime"`
+}
+
+// webhookPayload 是发送给端点的请求正文。
+type webhookPayload struct {
+	// Id 是事件的唯一标识符，同一事件重复投递时保持不变。
+	Id uint `json:"id"`
+	// Type 是事件的类型。
+	Type string `json:"type"`
+	// CreatedAt 是事件产生的时间。
+	CreatedAt time.Time `json:"created_at"`
+	// Data 是事件的载荷。
+	Data json.RawMessage `json:"data"`
+}
+
+// WebhookBody 是管理员创建或更新端点的请求。
+type WebhookBody struct {
+	// Name 是端点的名称。
+	Name string `json:"name" binding:"required,max=255"`
+	// Url 是接收请求的地址，必须为http或https地址。
+	Url string `json:"url" binding:"required,http_url,max=512"`
+	// EventTypes 是订阅的事件类型。
+	EventTypes []string `json:"event_types" binding:"required,min=1,unique,dive,oneof=ticket.submitted selection.changed status.changed interview.reminder"`
+	// Enabled 是端点是否启用，创建时默认为启用。
+	Enabled *bool `json:"enabled"`
+}
+
+// WebhookListItem 是端点列表项，不包含签名密钥。
+type WebhookListItem struct {
+	// Id 是端点的唯一标识符。
+	Id string `json:"id"`
+	// Name 是端点的名称。
+	Name string `json:"name"`
+	// Url 是接收请求的地址。
+	Url string `json:"url"`
+	// EventTypes 是订阅的事件类型。
+	EventTypes []string `json:"event_types"`
+	// Enabled 是端点是否启用。
+	Enabled bool `json:"enabled"`
+	// CreatedAt 是端点的创建时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetWebhookListResponse 是获取端点列表的响应。
+type GetWebhookListResponse struct {
+	Webhooks []WebhookListItem `json:"webhooks"`
+}
+
+// WebhookSecretResponse 是创建端点或更换密钥的响应，密钥只会在此时返回。
+type WebhookSecretResponse struct {
+	// Id 是端点的唯一标识符。
+	Id string `json:"id"`
+	// Secret 是签名密钥。
+	Secret string `json:"secret"`
+}
+
+type WebhookRequestUri struct {
+	// Id 是端点的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+}
+
+type WebhookDeliveryRequestUri struct {
+	// Id 是端点的唯一标识符。
+	Id string `uri:"id" binding:"required"`
+	// Delivery 是投递记录的唯一标识符。
+	Delivery uint `uri:"delivery" binding:"required"`
+}
+
+// WebhookDeliveryQuery 是查询投递记录的条件。
+type WebhookDeliveryQuery struct {
+	// Status 是投递状态。
+	Status string `form:"status" binding:"omitempty,oneof=pending delivered failed"`
+	// Limit 是返回的最大条数，默认为100。
+	Limit int `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// WebhookDeliveryListItem 是投递记录列表项。
+type WebhookDeliveryListItem struct {
+	// Id 是投递记录的唯一标识符。
+	Id uint `json:"id"`
+	// EventId 是事件的唯一标识符。
+	EventId uint `json:"event_id"`
+	// EventType 是事件的类型。
+	EventType string `json:"event_type"`
+	// Payload 是发送的请求正文。
+	Payload json.RawMessage `json:"payload"`
+	// Status 是投递状态。
+	Status string `json:"status"`
+	// Attempts 是已经尝试投递的次数。
+	Attempts int `json:"attempts"`
+	// NextAttemptAt 是下一次尝试投递的时间。
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// ResponseStatus 是最近一次请求的响应状态码。
+	ResponseStatus int `json:"response_status"`
+	// ResponseBody 是最近一次请求的响应正文。
+	ResponseBody string `json:"response_body"`
+	// DurationMs 是最近一次请求的耗时，单位为毫秒。
+	DurationMs int64 `json:"duration_ms"`
+	// LastError 是最近一次投递失败的原因。
+	LastError string `json:"last_error"`
+	// DeliveredAt 是投递成功的时间。
+	DeliveredAt *time.Time `json:"delivered_at"`
+	// CreatedAt 是投递记录的创建时间。
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetWebhookDeliveryListResponse 是获取投递记录的响应。
+type GetWebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryListItem `json:"deliveries"`
+}
+
+type WebhookNotFoundError struct{}
+
+func (e *WebhookNotFoundError) Error() string {
+	return "Webhook不存在"
+}
+
+type WebhookDeliveryNotFoundError struct{}
+
+func (e *WebhookDeliveryNotFoundError) Error() string {
+	return "投递记录不存在"
+}
+
+func getWebhookMaxAttempts() int {
+	return int(config.GetInt("WEBHOOK_MAX_ATTEMPTS", 8))
+}
+
+// GetWebhookList 获取全部端点。
+//
+// ctx 是上下文。
+func GetWebhookList(ctx context.Context) *GetWebhookListResponse {
+	slog.Debug("model.GetWebhookList: 正在获取Webhook列表")
+	srv := service.GetService()
+	var endpoints []WebhookEndpoint
+	err := srv.DB.WithContext(ctx).Model(&WebhookEndpoint{}).Order("id").Find(&endpoints).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetWebhookListResponse{Webhooks: make([]WebhookListItem, 0, len(endpoints))}
+	for _, v := range endpoints {
+		result.Webhooks = append(result.Webhooks, WebhookListItem{
+			Id:         v.EndpointId,
+			Name:       v.Name,
+			Url:        v.Url,
+			EventTypes: v.EventTypes,
+			Enabled:    v.Enabled != nil && *v.Enabled,
+			CreatedAt:  v.CreatedAt,
+		})
+	}
+	return &result
+}
+
+// CreateWebhook 创建端点，并返回端点的唯一标识符与签名密钥。
+//
+// ctx 是上下文。
+// body 是端点内容。
+func CreateWebhook(ctx context.Context, body *WebhookBody) *WebhookSecretResponse {
+	slog.Debug("model.CreateWebhook: 正在创建Webhook", "name", body.Name)
+	srv := service.GetService()
+	enabled := body.Enabled
+	if enabled == nil {
+		enabled = &[]bool{true}[0]
+	}
+	endpoint := WebhookEndpoint{
+		EndpointId: uuid.NewString(),
+		Name:       body.Name,
+		Url:        body.Url,
+		Secret:     webhook.NewSecret(),
+		EventTypes: body.EventTypes,
+		Enabled:    enabled,
+	}
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&endpoint).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "webhook.create", AuditTargetWebhook, endpoint.EndpointId, nil, &endpoint)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &WebhookSecretResponse{
+		Id:     endpoint.EndpointId,
+		Secret: endpoint.Secret,
+	}
+}
+
+// UpdateWebhook 更新端点，不会更换签名密钥。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+// body 是端点内容。
+func UpdateWebhook(ctx context.Context, endpointId string, body *WebhookBody) error {
+	slog.Debug("model.UpdateWebhook: 正在更新Webhook", "endpointId", endpointId)
+	before := findAuditSnapshot(ctx, &WebhookEndpoint{}, &WebhookEndpoint{EndpointId: endpointId})
+	if before == nil {
+		return &WebhookNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		after := *before
+		after.Name = body.Name
+		after.Url = body.Url
+		after.EventTypes = body.EventTypes
+		if body.Enabled != nil {
+			after.Enabled = body.Enabled
+		}
+		err := tx.Model(&WebhookEndpoint{}).Where(&WebhookEndpoint{EndpointId: endpointId}).
+			Select("name", "url", "event_types", "enabled").Updates(&after).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "webhook.update", AuditTargetWebhook, endpointId, before, &after)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// DeleteWebhook 删除端点，尚未完成的投递不会再发送。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+func DeleteWebhook(ctx context.Context, endpointId string) error {
+	slog.Debug("model.DeleteWebhook: 正在删除Webhook", "endpointId", endpointId)
+	before := findAuditSnapshot(ctx, &WebhookEndpoint{}, &WebhookEndpoint{EndpointId: endpointId})
+	if before == nil {
+		return &WebhookNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(&WebhookEndpoint{EndpointId: endpointId}).Delete(&WebhookEndpoint{}).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "webhook.delete", AuditTargetWebhook, endpointId, before, nil)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
+
+// RotateWebhookSecret 为端点生成新的签名密钥，旧密钥立即失效。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+func RotateWebhookSecret(ctx context.Context, endpointId string) (*WebhookSecretResponse, error) {
+	slog.Debug("model.RotateWebhookSecret: 正在更换Webhook密钥", "endpointId", endpointId)
+	if findWebhookEndpoint(ctx, endpointId) == nil {
+		return nil, &WebhookNotFoundError{}
+	}
+	srv := service.GetService()
+	secret := webhook.NewSecret()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&WebhookEndpoint{}).Where(&WebhookEndpoint{EndpointId: endpointId}).
+			Updates(&WebhookEndpoint{Secret: secret}).Error
+		if err != nil {
+			return err
+		}
+		return createAuditLog(ctx, tx, "webhook.secret.rotate", AuditTargetWebhook, endpointId, nil, nil)
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &WebhookSecretResponse{
+		Id:     endpointId,
+		Secret: secret,
+	}, nil
+}
+
+// findWebhookEndpoint 获取端点，不存在或已删除时返回nil。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+func findWebhookEndpoint(ctx context.Context, endpointId string) *WebhookEndpoint {
+	srv := service.GetService()
+	var endpoint WebhookEndpoint
+	err := srv.DB.WithContext(ctx).Model(&WebhookEndpoint{}).Where(&WebhookEndpoint{
+		EndpointId: endpointId,
+	}).First(&endpoint).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return &endpoint
+}
+
+// EnqueueWebhookDeliveries 为订阅了该事件的已启用端点创建投递记录，是事件的消费方之一。
+// 同一事件对同一端点只会创建一条记录，因此可以重复执行。
+//
+// ctx 是上下文。
+// event 是要投递的事件。
+func EnqueueWebhookDeliveries(ctx context.Context, event *OutboxEvent) error {
+	srv := service.GetService()
+	var endpoints []WebhookEndpoint
+	err := srv.DB.WithContext(ctx).Model(&WebhookEndpoint{}).Where(&WebhookEndpoint{
+		Enabled: &[]bool{true}[0],
+	}).Find(&endpoints).Error
+	if err != nil {
+		return err
+	}
+	var payload []byte
+	for _, endpoint := range endpoints {
+		subscribed := false
+		for _, v := range endpoint.EventTypes {
+			if v == event.EventType {
+				subscribed = true
+			}
+		}
+		if !subscribed {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(&webhookPayload{
+				Id:        event.ID,
+				Type:      event.EventType,
+				CreatedAt: event.CreatedAt,
+				Data:      json.RawMessage(event.Payload),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		slog.Debug("model.EnqueueWebhookDeliveries: 正在创建投递记录", "endpointId", endpoint.EndpointId, "eventId", event.ID)
+		err := srv.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&WebhookDelivery{
+			EndpointId:    endpoint.EndpointId,
+			EventId:       event.ID,
+			EventType:     event.EventType,
+			Payload:       string(payload),
+			Status:        WebhookDeliveryStatusPending,
+			NextAttemptAt: time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDueWebhookDeliveries 获取到期需要投递的记录，按创建的顺序排列。
+//
+// ctx 是上下文。
+// limit 是返回的最大条数。
+func GetDueWebhookDeliveries(ctx context.Context, limit int) []WebhookDelivery {
+	srv := service.GetService()
+	var deliveries []WebhookDelivery
+	err := srv.DB.WithContext(ctx).Model(&WebhookDelivery{}).Where(&WebhookDelivery{
+		Status: WebhookDeliveryStatusPending,
+	}).Where("next_attempt_at <= ?", time.Now()).Order("id").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return deliveries
+}
+
+// SendWebhookDelivery 发送一次投递并记录结果。
+// 失败时按指数退避安排重试，间隔最长为1小时；重试次数用尽、端点已停用或已删除时标记为失败。
+// 最大尝试次数由WEBHOOK_MAX_ATTEMPTS指定，默认为8次。
+//
+// ctx 是上下文。
+// delivery 是投递记录。
+func SendWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) {
+	slog.Debug("model.SendWebhookDelivery: 正在投递", "id", delivery.ID, "endpointId", delivery.EndpointId)
+	srv := service.GetService()
+	// 先推迟下一次尝试的时间，避免任务执行时间较长时被其他实例重复发送；
+	// 只认领已到期的投递，其他实例读到的旧副本无法再次认领
+	now := time.Now()
+	result := srv.DB.WithContext(ctx).Model(&WebhookDelivery{}).Where(&WebhookDelivery{
+		ID:     delivery.ID,
+		Status: WebhookDeliveryStatusPending,
+	}).Where("attempts = ? AND next_attempt_at <= ?", delivery.Attempts, now).
+		Update("next_attempt_at", now.Add(time.Minute))
+	if result.Error != nil {
+		slog.Error("调用ORM失败。", "error", result.Error)
+		panic(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		slog.Debug("model.SendWebhookDelivery: 投递已被其他实例处理", "id", delivery.ID)
+		return
+	}
+	attempts := delivery.Attempts + 1
+	update := map[string]interface{}{
+		"attempts": attempts,
+	}
+	endpoint := findWebhookEndpoint(ctx, delivery.EndpointId)
+	if endpoint == nil || endpoint.Enabled == nil || !*endpoint.Enabled {
+		slog.Warn("model.SendWebhookDelivery: 端点已停用或已删除", "id", delivery.ID, "endpointId", delivery.EndpointId)
+		update["status"] = WebhookDeliveryStatusFailed
+		update["last_error"] = "端点已停用或已删除"
+	} else {
+		result, err := webhook.Send(ctx, endpoint.Url, endpoint.Secret, delivery.EventType,
+			strconv.FormatUint(uint64(delivery.ID), 10), []byte(delivery.Payload))
+		update["response_status"] = result.StatusCode
+		update["response_body"] = result.Body
+		update["duration_ms"] = result.Duration.Milliseconds()
+		if err == nil {
+			update["status"] = WebhookDeliveryStatusDelivered
+			update["last_error"] = ""
+			update["delivered_at"] = time.Now()
+		} else {
+			slog.Warn("model.SendWebhookDelivery: 投递失败", "id", delivery.ID, "attempts", attempts, "error", err)
+			update["last_error"] = err.Error()
+			backoff := time.Hour
+			if attempts < 12 {
+				backoff = time.Second << attempts
+			}
+			update["next_attempt_at"] = time.Now().Add(backoff)
+			if attempts >= getWebhookMaxAttempts() {
+				update["status"] = WebhookDeliveryStatusFailed
+			}
+		}
+	}
+	err := srv.DB.WithContext(ctx).Model(delivery).Updates(update).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+}
+
+// GetWebhookDeliveryList 获取端点的投递记录，按时间倒序排列。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+// query 是查询条件。
+func GetWebhookDeliveryList(ctx context.Context, endpointId string, query *WebhookDeliveryQuery) (*GetWebhookDeliveryListResponse, error) {
+	slog.Debug("model.GetWebhookDeliveryList: 正在获取投递记录", "endpointId", endpointId, "query", query)
+	if findWebhookEndpoint(ctx, endpointId) == nil {
+		return nil, &WebhookNotFoundError{}
+	}
+	srv := service.GetService()
+	limit := query.Limit
+	if limit == 0 {
+		limit = 100
+	}
+	var deliveries []WebhookDelivery
+	err := srv.DB.WithContext(ctx).Model(&WebhookDelivery{}).Where(&WebhookDelivery{
+		EndpointId: endpointId,
+		Status:     query.Status,
+	}).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	result := GetWebhookDeliveryListResponse{Deliveries: make([]WebhookDeliveryListItem, 0, len(deliveries))}
+	for _, v := range deliveries {
+		result.Deliveries = append(result.Deliveries, WebhookDeliveryListItem{
+			Id:             v.ID,
+			EventId:        v.EventId,
+			EventType:      v.EventType,
+			Payload:        json.RawMessage(v.Payload),
+			Status:         v.Status,
+			Attempts:       v.Attempts,
+			NextAttemptAt:  v.NextAttemptAt,
+			ResponseStatus: v.ResponseStatus,
+			ResponseBody:   v.ResponseBody,
+			DurationMs:     v.DurationMs,
+			LastError:      v.LastError,
+			DeliveredAt:    v.DeliveredAt,
+			CreatedAt:      v.CreatedAt,
+		})
+	}
+	return &result, nil
+}
+
+// RedeliverWebhook 重新投递一条记录，无论之前是否成功，尝试次数从零开始计算。
+// 请求正文与投递ID保持不变，接收方可以据此去重。
+//
+// ctx 是上下文。
+// endpointId 是端点的唯一标识符。
+// deliveryId 是投递记录的唯一标识符。
+func RedeliverWebhook(ctx context.Context, endpointId string, deliveryId uint) error {
+	slog.Debug("model.RedeliverWebhook: 正在重新投递", "endpointId", endpointId, "deliveryId", deliveryId)
+	if findWebhookEndpoint(ctx, endpointId) == nil {
+		return &WebhookNotFoundError{}
+	}
+	srv := service.GetService()
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&WebhookDelivery{}).Where(&WebhookDelivery{
+			ID:         deliveryId,
+			EndpointId: endpointId,
+		}).Updates(map[string]interface{}{
+			"status":          WebhookDeliveryStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return &WebhookDeliveryNotFoundError{}
+		}
+		return createAuditLog(ctx, tx, "webhook.redeliver", AuditTargetWebhook, endpointId, nil,
+			map[string]interface{}{"delivery_id": deliveryId})
+	})
+	if err != nil {
+		if v, ok := err.(*WebhookDeliveryNotFoundError); ok {
+			return v
+		}
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	return nil
+}
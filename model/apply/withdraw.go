@@ -0,0 +1,94 @@
+package apply
+
+import (
+	"context"
+	"elab-backend/service"
+	"gorm.io/gorm"
+	"log/slog"
+	"time"
+)
+
+// WithdrawRequest 是撤回申请的请求。
+type WithdrawRequest struct {
+	// Reason 是撤回的原因，可以为空。
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// withdrawSnapshot 是审计记录中的申请状态。
+type withdrawSnapshot struct {
+	Submitted      bool   `json:"submitted"`
+	Withdrawn      bool   `json:"withdrawn"`
+	WithdrawReason string `json:"withdraw_reason"`
+	OfferGroup     string `json:"offer_group"`
+}
+
+// WithdrawTicket 撤回用户已提交的申请，但不删除账号和已填写的数据。
+// 撤回会释放用户选择的面试房间与保留的座位、撤销录取结果，并通知工作人员。
+// 截止前用户可以通过UpdateTicket重新提交申请。调用方需要持有房间选择的锁。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// reason 是撤回的原因。
+func WithdrawTicket(ctx context.Context, openid string, reason string) error {
+	slog.Debug("model.WithdrawTicket: 正在撤回申请", "openid", openid)
+	ticket := findTicket(ctx, openid)
+	if ticket == nil || ticket.Submitted == nil || !*ticket.Submitted {
+		slog.Debug("model.WithdrawTicket: 申请表不存在或未提交", "openid", openid)
+		return &TicketNotFoundError{}
+	}
+	selectedRoomId, selected := CheckIsAlreadySelected(ctx, openid)
+	srv := service.GetService()
+	now := time.Now()
+	var heldRoomId string
+	// 释放房间与撤回申请在同一事务中，避免撤回失败时房间已经被释放
+	err := srv.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if selected {
+			slog.Debug("model.WithdrawTicket: 正在释放房间选择", "openid", openid)
+			err := clearSelection(ctx, tx, openid, selectedRoomId)
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		heldRoomId, err = releaseSeatHold(tx, openid)
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Ticket{}).Where(&Ticket{OpenId: openid}).
+			Select("submitted", "withdrawn", "withdrawn_at", "withdraw_reason", "offer_group", "offered_at").
+			Updates(&Ticket{
+				Submitted:      &[]bool{false}[0],
+				Withdrawn:      &[]bool{true}[0],
+				WithdrawnAt:    &now,
+				WithdrawReason: reason,
+			}).Error
+		if err != nil {
+			return err
+		}
+		err = createAuditLog(ctx, tx, "ticket.withdraw", AuditTargetTicket, openid,
+			&withdrawSnapshot{Submitted: true, OfferGroup: ticket.OfferGroup},
+			&withdrawSnapshot{Withdrawn: true, WithdrawReason: reason})
+		if err != nil {
+			return err
+		}
+		message := ticket.Name + "（" + ticket.StudentId + "）撤回了申请"
+		if reason != "" {
+			message += "，原因：" + reason
+		}
+		err = createStaffNotice(ctx, tx, NoticeTypeWithdraw, openid, message)
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(ctx, tx, OutboxEventStatusChanged, openid, &StatusChangedPayload{
+			OpenId: openid,
+			Status: ApplicationStatusWithdrawn,
+		})
+	})
+	if err != nil {
+		slog.Error("调用ORM失败。", "error", err)
+		panic(err)
+	}
+	publishRoomOccupancy(ctx, selectedRoomId, heldRoomId)
+	slog.Info("model.WithdrawTicket: 用户撤回了申请", "openid", openid)
+	return nil
+}
@@ -11,7 +11,12 @@ func Init() {
 	svc := service.GetService()
 	slog.Debug("model.Init: 正在迁移数据库")
 	err := svc.DB.AutoMigrate(
-		&apply.Config{}, &apply.Room{}, &apply.TextForm{}, &apply.Ticket{}, &apply.Selection{}, &apply.Question{})
+		&apply.Config{}, &apply.Room{}, &apply.TextForm{}, &apply.Ticket{}, &apply.Selection{}, &apply.Question{},
+		&apply.Revision{}, &apply.Section{}, &apply.Group{}, &apply.GroupRestriction{},
+		&apply.Attachment{}, &apply.TicketPreference{},
+		&apply.StaffNotice{}, &apply.AccountDeletion{}, &apply.AuditLog{}, &apply.OutboxEvent{},
+		&apply.WebhookEndpoint{}, &apply.WebhookDelivery{}, &apply.InterviewReminder{},
+		&apply.SeatHold{})
 	if err != nil {
 		slog.Error("调用ORM失败。", "error", err)
 		panic(err)
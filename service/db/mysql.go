@@ -5,6 +5,7 @@ import (
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"log/slog"
+	"net/url"
 	"os"
 )
 
@@ -17,13 +18,20 @@ func NewService() *gorm.DB {
 	host := os.Getenv("MYSQL_HOST")
 	port := os.Getenv("MYSQL_PORT")
 	database := os.Getenv("MYSQL_DATABASE")
+	// 数据库中的时间按MYSQL_LOC指定的时区保存，默认为UTC，与容器的时区无关。
+	// 已有数据按其他时区保存时需要设置为原来的时区，如“Asia/Shanghai”。
+	loc := os.Getenv("MYSQL_LOC")
+	if loc == "" {
+		loc = "UTC"
+	}
 	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=%s",
 		username,
 		password,
 		host,
 		port,
 		database,
+		url.QueryEscape(loc),
 	)
 	localDb, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
 	if err != nil {
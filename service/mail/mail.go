@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// Message 是一封纯文本邮件。
+type Message struct {
+	// To 是收件人地址。
+	To string
+	// Subject 是邮件主题。
+	Subject string
+	// Body 是邮件正文。
+	Body string
+}
+
+// Sender 是邮件发送服务。
+type Sender interface {
+	// Send 发送邮件。
+	//
+	// msg 是要发送的邮件。
+	Send(ctx context.Context, msg *Message) error
+}
+
+// NewService 根据MAIL_DRIVER创建邮件发送服务，可选“smtp”和“log”。
+// 未指定时，配置了SMTP_HOST则使用“smtp”，否则使用只记录日志的“log”。
+func NewService() Sender {
+	driver := os.Getenv("MAIL_DRIVER")
+	if driver == "" && os.Getenv("SMTP_HOST") != "" {
+		driver = "smtp"
+	}
+	slog.Info("service.mail.NewService: 正在初始化邮件服务", "driver", driver)
+	switch driver {
+	case "smtp":
+		return NewSMTPSender()
+	case "", "log":
+		return &LogSender{}
+	}
+	slog.Error("未知的邮件服务", "driver", driver)
+	panic("未知的邮件服务：" + driver)
+}
+
+// LogSender 只把邮件写入日志，用于开发环境。
+type LogSender struct{}
+
+func (s *LogSender) Send(ctx context.Context, msg *Message) error {
+	slog.Info("service.mail.LogSender: 邮件未实际发送", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
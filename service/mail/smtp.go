@@ -0,0 +1,135 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+// SMTPSender 通过SMTP服务器发送邮件。
+// 服务器支持STARTTLS时会自动启用，因此也可以直接对接MailHog等不加密的本地测试服务器。
+type SMTPSender struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	timeout  time.Duration
+}
+
+// NewSMTPSender 创建SMTP邮件发送服务。
+// 使用SMTP_HOST、SMTP_PORT（默认为25）、SMTP_USERNAME、SMTP_PASSWORD与SMTP_FROM配置，
+// 未配置用户名时不进行认证。SMTP_TIMEOUT是连接与发送一封邮件的总超时时间，默认为30秒。
+func NewSMTPSender() *SMTPSender {
+	host := os.Getenv("SMTP_HOST")
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if host == "" || from == "" {
+		slog.Error("SMTP_HOST与SMTP_FROM不能为空")
+		panic("SMTP_HOST与SMTP_FROM不能为空")
+	}
+	timeout := 30 * time.Second
+	if value := os.Getenv("SMTP_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			slog.Error("无法解析SMTP_TIMEOUT", "error", err)
+			panic(err)
+		}
+		timeout = parsed
+	}
+	return &SMTPSender{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     from,
+		timeout:  timeout,
+	}
+}
+
+// Send 发送邮件。连接受ctx与SMTP_TIMEOUT限制，服务器无响应时不会无限期阻塞。
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	slog.Debug("service.mail.SMTPSender: 正在发送邮件", "to", msg.To, "subject", msg.Subject)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		err = client.Auth(smtp.PlainAuth("", s.username, s.password, s.host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(s.from)
+	if err != nil {
+		return err
+	}
+	err = client.Rcpt(msg.To)
+	if err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(s.build(msg))
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// build 生成符合RFC 5322的邮件内容，主题与正文使用UTF-8编码。
+func (s *SMTPSender) build(msg *Message) []byte {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buffer.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buffer.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buffer.WriteString(encoded + "\r\n")
+	return buffer.Bytes()
+}
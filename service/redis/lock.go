@@ -2,6 +2,8 @@ package redis
 
 import (
 	"context"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"time"
 )
@@ -10,6 +12,12 @@ const Timeout = time.Second * 10
 const RetryTimes = 50
 const RetryInterval = time.Millisecond * 200
 
+// unlockScript 只在锁仍由自己持有时删除锁，避免锁过期后删除其他实例获取的锁。
+var unlockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`)
+
+// renewScript 只在锁仍由自己持有时延长锁的有效期。
+var renewScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`)
+
 type GetLockTimeoutError struct{}
 
 func (e *GetLockTimeoutError) Error() string {
@@ -22,8 +30,9 @@ func (e *GetLockTimeoutError) Error() string {
 // key 是锁的键。
 func GetLock(ctx context.Context, key string) (func(), error) {
 	slog.Debug("redis.GetLock: 正在获取锁", "key", key)
+	token := uuid.NewString()
 	for i := 0; i < RetryTimes; i++ {
-		ok, err := client.SetNX(ctx, key, 1, Timeout).Result()
+		ok, err := client.SetNX(ctx, key, token, Timeout).Result()
 		if err != nil {
 			slog.Error("无法获取锁", "error", err)
 			return nil, err
@@ -32,7 +41,7 @@ func GetLock(ctx context.Context, key string) (func(), error) {
 			slog.Debug("redis.GetLock: 成功获取锁", "key", key)
 			return func() {
 				slog.Debug("redis.GetLock: 正在释放锁", "key", key)
-				client.Del(ctx, key)
+				unlockScript.Run(ctx, client, []string{key}, token)
 			}, nil
 		}
 		slog.Debug("redis.GetLock: 未能获取锁，正在重试", "key", key, "retry", i)
@@ -41,3 +50,53 @@ func GetLock(ctx context.Context, key string) (func(), error) {
 	slog.Debug("redis.GetLock: 未能获取锁，超时", "key", key)
 	return nil, &GetLockTimeoutError{}
 }
+
+// TryLock 尝试获取锁，不会重试，适用于多个实例中只需一个执行的定时任务。
+// 持有锁期间会每隔ttl的三分之一自动续期，直到调用unlock，因此持有锁的操作耗时超过ttl也不会被其他实例获取。
+// 获取失败时返回的unlock为nil。
+//
+// ctx 是上下文。
+// key 是锁的键。
+// ttl 是锁的有效期，实例异常退出后锁最多在ttl之后被释放。
+func TryLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	slog.Debug("redis.TryLock: 正在尝试获取锁", "key", key)
+	token := uuid.NewString()
+	ok, err := client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		slog.Error("无法获取锁", "error", err)
+		return nil, err
+	}
+	if !ok {
+		slog.Debug("redis.TryLock: 锁已被占用", "key", key)
+		return nil, nil
+	}
+	stop := make(chan struct{})
+	go renewLock(key, token, ttl, stop)
+	return func() {
+		slog.Debug("redis.TryLock: 正在释放锁", "key", key)
+		close(stop)
+		unlockScript.Run(context.Background(), client, []string{key}, token)
+	}, nil
+}
+
+// renewLock 定期延长锁的有效期，直到stop被关闭或锁已不再由自己持有。
+func renewLock(key string, token string, ttl time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			renewed, err := renewScript.Run(context.Background(), client, []string{key}, token, ttl.Milliseconds()).Int()
+			if err != nil {
+				slog.Warn("redis.renewLock: 续期失败", "key", key, "error", err)
+				continue
+			}
+			if renewed == 0 {
+				slog.Warn("redis.renewLock: 锁已不再由当前实例持有", "key", key)
+				return
+			}
+		}
+	}
+}
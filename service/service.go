@@ -3,7 +3,10 @@ package service
 import (
 	"elab-backend/service/auth0"
 	"elab-backend/service/db"
+	"elab-backend/service/mail"
 	"elab-backend/service/redis"
+	"elab-backend/service/storage"
+	"elab-backend/util/crypto"
 	"github.com/auth0/go-auth0/management"
 	"github.com/pkg/errors"
 	libRedis "github.com/redis/go-redis/v9"
@@ -15,6 +18,8 @@ type Service struct {
 	DB      *gorm.DB
 	Redis   *libRedis.Client
 	AuthAPI *management.Management
+	Storage storage.Storage
+	Mail    mail.Sender
 }
 
 var service *Service
@@ -22,9 +27,13 @@ var service *Service
 func Init() {
 	slog.Info("正在初始化服务")
 	service = &Service{}
+	// 加密字段的序列化器需要在迁移数据库之前注册
+	crypto.Init()
 	service.Redis = redis.NewService()
 	service.DB = db.NewService()
 	service.AuthAPI = auth0.NewService()
+	service.Storage = storage.NewService()
+	service.Mail = mail.NewService()
 }
 
 func GetService() *Service {
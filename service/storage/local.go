@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 是基于本地文件系统的存储后端。
+type LocalStorage struct {
+	// Root 是文件存放的根目录。
+	Root string
+}
+
+// NewLocalStorage 创建本地存储后端，根目录由STORAGE_LOCAL_ROOT指定，默认为“data”。
+func NewLocalStorage() *LocalStorage {
+	root := os.Getenv("STORAGE_LOCAL_ROOT")
+	if root == "" {
+		root = "data"
+	}
+	slog.Info("service.storage.NewLocalStorage: 正在初始化本地存储", "root", root)
+	err := os.MkdirAll(root, 0o750)
+	if err != nil {
+		slog.Error("无法创建存储目录", "error", err)
+		panic(err)
+	}
+	return &LocalStorage{Root: root}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	// 防止键中的“..”逃逸出根目录
+	path := filepath.Join(s.Root, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.Root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", os.ErrInvalid
+	}
+	return path, nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	slog.Debug("service.storage.LocalStorage.Put: 正在保存文件", "key", key, "size", size)
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, reader)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	slog.Debug("service.storage.LocalStorage.Get: 正在读取文件", "key", key)
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	slog.Debug("service.storage.LocalStorage.Delete: 正在删除文件", "key", key)
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// S3Storage 是兼容S3协议的存储后端，可以连接AWS S3或本地的MinIO。
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3Storage 创建S3存储后端。
+// 使用S3_ENDPOINT、S3_ACCESS_KEY、S3_SECRET_KEY、S3_BUCKET和S3_USE_SSL进行配置，
+// 存储桶不存在时会自动创建。
+func NewS3Storage() *S3Storage {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	bucket := os.Getenv("S3_BUCKET")
+	useSSL := os.Getenv("S3_USE_SSL") == "true"
+	slog.Info("service.storage.NewS3Storage: 正在连接S3", "endpoint", endpoint, "bucket", bucket, "ssl", useSSL)
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+		Secure: useSSL,
+		Region: os.Getenv("S3_REGION"),
+	})
+	if err != nil {
+		slog.Error("无法创建S3客户端", "error", err)
+		panic(err)
+	}
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		slog.Error("无法连接S3", "error", err)
+		panic(err)
+	}
+	if !exists {
+		slog.Info("service.storage.NewS3Storage: 存储桶不存在，正在创建", "bucket", bucket)
+		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: os.Getenv("S3_REGION")})
+		if err != nil {
+			slog.Error("无法创建存储桶", "error", err)
+			panic(err)
+		}
+	}
+	return &S3Storage{client: client, bucket: bucket}
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	slog.Debug("service.storage.S3Storage.Put: 正在上传文件", "key", key, "size", size)
+	_, err := s.client.PutObject(ctx, s.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	slog.Debug("service.storage.S3Storage.Get: 正在下载文件", "key", key)
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject不会立即请求，通过Stat确认对象存在
+	_, err = object.Stat()
+	if err != nil {
+		_ = object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	slog.Debug("service.storage.S3Storage.Delete: 正在删除文件", "key", key)
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
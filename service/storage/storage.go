@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Storage 是文件存储后端。
+type Storage interface {
+	// Put 保存文件。
+	//
+	// key 是文件的键。
+	// reader 是文件内容。
+	// size 是文件大小。
+	// contentType 是文件的MIME类型。
+	Put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error
+	// Get 读取文件，调用方需要关闭返回的Reader。
+	//
+	// key 是文件的键。
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误。
+	//
+	// key 是文件的键。
+	Delete(ctx context.Context, key string) error
+}
+
+// NewService 根据STORAGE_DRIVER创建存储后端，可选“local”和“s3”，默认为“local”。
+func NewService() Storage {
+	driver := os.Getenv("STORAGE_DRIVER")
+	slog.Info("service.storage.NewService: 正在初始化存储服务", "driver", driver)
+	switch driver {
+	case "s3":
+		return NewS3Storage()
+	case "", "local":
+		return NewLocalStorage()
+	}
+	slog.Error("未知的存储后端", "driver", driver)
+	panic("未知的存储后端：" + driver)
+}
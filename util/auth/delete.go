@@ -2,35 +2,28 @@ package auth
 
 import (
 	"context"
-	"elab-backend/model/apply"
 	"elab-backend/service"
+	"github.com/auth0/go-auth0/management"
+	"github.com/pkg/errors"
 	"log/slog"
+	"net/http"
 )
 
-func DeleteAccount(ctx context.Context, openid string) error {
+// DeleteIdentity 删除Auth0中的用户，用户已不存在时视为成功。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+func DeleteIdentity(ctx context.Context, openid string) error {
+	slog.Debug("util.auth.DeleteIdentity: 正在删除Auth0用户", "openid", openid)
 	svc := service.GetService()
-	err := svc.DB.WithContext(ctx).Model(&apply.Ticket{}).Where(&apply.Ticket{OpenId: openid}).Delete(&apply.Ticket{}).Error
+	err := svc.AuthAPI.User.Delete(ctx, openid)
 	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
-	}
-	exists := apply.CheckIsSelectionExists(ctx, openid)
-	if exists {
-		err = apply.ClearSelection(ctx, openid)
-		if err != nil {
-			slog.Error("调用ORM失败。", "error", err)
-			panic(err)
+		var managementError management.Error
+		if errors.As(err, &managementError) && managementError.Status() == http.StatusNotFound {
+			return nil
 		}
-	}
-	err = svc.DB.WithContext(ctx).Model(&apply.TextForm{}).Where(&apply.TextForm{OpenId: openid}).Delete(&apply.TextForm{}).Error
-	if err != nil {
-		slog.Error("调用ORM失败。", "error", err)
-		panic(err)
-	}
-	err = svc.AuthAPI.User.Delete(ctx, openid)
-	if err != nil {
 		slog.Error("调用Auth0 API失败。", "error", err)
-		panic(err)
+		return err
 	}
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -15,12 +16,31 @@ var v *validator.Validator
 
 type CustomClaims struct {
 	Scope string `json:"scope"`
+	// Permissions 是Auth0 RBAC下发的权限列表。
+	Permissions []string `json:"permissions"`
 }
 
 func (c CustomClaims) Validate(ctx context.Context) error {
 	return nil
 }
 
+// HasScope 检查Token是否包含指定的scope或权限。
+//
+// scope 是需要检查的scope。
+func (c CustomClaims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	for _, p := range c.Permissions {
+		if p == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func GetValidator() *validator.Validator {
 	slog.Debug("util.auth.GetValidator: 正在获取验证器")
 	if v != nil {
@@ -64,3 +84,24 @@ func GetToken(ctx *gin.Context) *validator.ValidatedClaims {
 	}
 	return token.(*validator.ValidatedClaims)
 }
+
+// GetAdminScope 获取管理员所需的scope，默认为“admin”。
+func GetAdminScope() string {
+	scope := os.Getenv("AUTH0_ADMIN_SCOPE")
+	if scope == "" {
+		return "admin"
+	}
+	return scope
+}
+
+// IsAdmin 检查当前用户是否为管理员。
+//
+// ctx 是gin上下文。
+func IsAdmin(ctx *gin.Context) bool {
+	token := GetToken(ctx)
+	claims, ok := token.CustomClaims.(*CustomClaims)
+	if !ok {
+		return false
+	}
+	return claims.HasScope(GetAdminScope())
+}
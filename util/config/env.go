@@ -0,0 +1,109 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetString 获取字符串类型的环境变量，为空时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetString(key string, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// GetInt 获取整数类型的环境变量，为空或无法解析时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetInt(key string, fallback int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		slog.Error("无法解析环境变量，使用默认值", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return result
+}
+
+// GetBool 获取布尔类型的环境变量，为空或无法解析时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	result, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Error("无法解析环境变量，使用默认值", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return result
+}
+
+// GetDuration 获取时长类型的环境变量，格式如“24h”、“30m”，为空或无法解析时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	result, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Error("无法解析环境变量，使用默认值", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return result
+}
+
+// GetList 获取以逗号分隔的环境变量，为空时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// GetTime 获取时间类型的环境变量，格式为RFC3339，如“2023-10-01T00:00:00+08:00”，
+// 为空或无法解析时返回默认值。
+//
+// key 是环境变量名。
+// fallback 是默认值。
+func GetTime(key string, fallback *time.Time) *time.Time {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	result, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		slog.Error("无法解析环境变量，使用默认值", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return &result
+}
@@ -0,0 +1,182 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// keys 是按版本号索引的加密密钥。
+	keys map[int]cipher.AEAD
+	// currentVersion 是加密新数据时使用的密钥版本。
+	currentVersion int
+	// indexKey 是计算盲索引的密钥。
+	indexKey []byte
+	keyOnce  sync.Once
+)
+
+type InvalidCiphertextError struct{}
+
+func (e *InvalidCiphertextError) Error() string {
+	return "密文格式错误"
+}
+
+type UnknownKeyVersionError struct {
+	// Version 是密文使用的密钥版本。
+	Version int
+}
+
+func (e *UnknownKeyVersionError) Error() string {
+	return fmt.Sprintf("未知的密钥版本：%d", e.Version)
+}
+
+// loadKeys 加载字段加密所需的密钥。
+//
+// FIELD_ENCRYPTION_KEYS 是以逗号分隔的“版本号:Base64编码的32字节密钥”，如“1:xxx,2:yyy”，
+// 旧版本的密钥用于解密轮换前的数据。
+// FIELD_ENCRYPTION_KEY_VERSION 是加密新数据使用的版本，默认为最大的版本号。
+// FIELD_INDEX_KEY 是计算盲索引的密钥，修改后需要重新计算全部索引。
+func loadKeys() {
+	keyOnce.Do(func() {
+		value := os.Getenv("FIELD_ENCRYPTION_KEYS")
+		if value == "" {
+			slog.Error("未配置FIELD_ENCRYPTION_KEYS")
+			panic("未配置FIELD_ENCRYPTION_KEYS")
+		}
+		keys = make(map[int]cipher.AEAD)
+		for _, item := range strings.Split(value, ",") {
+			versionText, keyText, ok := strings.Cut(strings.TrimSpace(item), ":")
+			version, err := strconv.Atoi(versionText)
+			if !ok || err != nil || version <= 0 {
+				slog.Error("无法解析FIELD_ENCRYPTION_KEYS", "item", versionText)
+				panic("无法解析FIELD_ENCRYPTION_KEYS")
+			}
+			key, err := base64.StdEncoding.DecodeString(keyText)
+			if err != nil || len(key) != 32 {
+				slog.Error("加密密钥需要是Base64编码的32字节密钥", "version", version)
+				panic("无法解析FIELD_ENCRYPTION_KEYS")
+			}
+			block, err := aes.NewCipher(key)
+			if err != nil {
+				panic(err)
+			}
+			aead, err := cipher.NewGCM(block)
+			if err != nil {
+				panic(err)
+			}
+			keys[version] = aead
+			if version > currentVersion {
+				currentVersion = version
+			}
+		}
+		if value := os.Getenv("FIELD_ENCRYPTION_KEY_VERSION"); value != "" {
+			version, err := strconv.Atoi(value)
+			if err != nil || keys[version] == nil {
+				slog.Error("FIELD_ENCRYPTION_KEY_VERSION不在FIELD_ENCRYPTION_KEYS中", "version", value)
+				panic("无法解析FIELD_ENCRYPTION_KEY_VERSION")
+			}
+			currentVersion = version
+		}
+		indexKey = []byte(os.Getenv("FIELD_INDEX_KEY"))
+		if len(indexKey) == 0 {
+			slog.Error("未配置FIELD_INDEX_KEY")
+			panic("未配置FIELD_INDEX_KEY")
+		}
+		slog.Info("util.crypto.loadKeys: 已加载字段加密密钥", "count", len(keys), "version", currentVersion)
+	})
+}
+
+// Encrypt 使用当前版本的密钥加密字符串，结果形如“v2:Base64”。空字符串不加密。
+//
+// plaintext 是明文。
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	loadKeys()
+	aead := keys[currentVersion]
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "v" + strconv.Itoa(currentVersion) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密Encrypt生成的密文。
+// 不带版本前缀的值视为加密启用前写入的明文，原样返回。
+//
+// ciphertext 是密文。
+func Decrypt(ciphertext string) (string, error) {
+	version, payload, ok := parseCiphertext(ciphertext)
+	if !ok {
+		return ciphertext, nil
+	}
+	loadKeys()
+	aead := keys[version]
+	if aead == nil {
+		return "", &UnknownKeyVersionError{Version: version}
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", &InvalidCiphertextError{}
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", &InvalidCiphertextError{}
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation 检查数据库中的值是否需要用当前版本的密钥重新加密，包括尚未加密的明文。
+//
+// value 是数据库中保存的值。
+func NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	loadKeys()
+	version, _, ok := parseCiphertext(value)
+	return !ok || version != currentVersion
+}
+
+// BlindIndex 计算用于等值查询的盲索引，相同的值总是得到相同的索引，空字符串的索引为空。
+//
+// value 是明文。
+func BlindIndex(value string) string {
+	if value == "" {
+		return ""
+	}
+	loadKeys()
+	mac := hmac.New(sha256.New, indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseCiphertext 拆分密文的版本号与内容。
+func parseCiphertext(value string) (int, string, bool) {
+	if !strings.HasPrefix(value, "v") {
+		return 0, "", false
+	}
+	versionText, payload, ok := strings.Cut(value[1:], ":")
+	if !ok {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(versionText)
+	if err != nil {
+		return 0, "", false
+	}
+	return version, payload, true
+}
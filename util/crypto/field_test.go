@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testKey1 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	testKey2 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+)
+
+// useKeys 使用指定的配置重新加载密钥。
+func useKeys(t *testing.T, value string, version string) {
+	t.Setenv("FIELD_ENCRYPTION_KEYS", value)
+	t.Setenv("FIELD_ENCRYPTION_KEY_VERSION", version)
+	t.Setenv("FIELD_INDEX_KEY", "index-key")
+	keys = nil
+	currentVersion = 0
+	indexKey = nil
+	keyOnce = sync.Once{}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	useKeys(t, "1:"+testKey1, "")
+	for _, plaintext := range []string{"", "张三", "2023000000", "a:b,c"} {
+		ciphertext, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt(%q) error: %v", plaintext, err)
+		}
+		if plaintext != "" && !strings.HasPrefix(ciphertext, "v1:") {
+			t.Errorf("Encrypt(%q) = %q, want v1 prefix", plaintext, ciphertext)
+		}
+		got, err := Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("Decrypt(%q) error: %v", ciphertext, err)
+		}
+		if got != plaintext {
+			t.Errorf("Decrypt(Encrypt(%q)) = %q", plaintext, got)
+		}
+	}
+}
+
+func TestDecryptWithOlderKey(t *testing.T) {
+	useKeys(t, "1:"+testKey1, "")
+	old, err := Encrypt("张三")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeys(t, "1:"+testKey1+",2:"+testKey2, "")
+	got, err := Decrypt(old)
+	if err != nil || got != "张三" {
+		t.Errorf("Decrypt(old) = %q, %v", got, err)
+	}
+	current, err := Encrypt("张三")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(current, "v2:") {
+		t.Errorf("Encrypt after rotation = %q, want v2 prefix", current)
+	}
+	useKeys(t, "2:"+testKey2, "")
+	if _, err := Decrypt(old); err == nil {
+		t.Error("Decrypt with removed key version should fail")
+	}
+}
+
+func TestNeedsRotation(t *testing.T) {
+	useKeys(t, "1:"+testKey1, "")
+	old, _ := Encrypt("张三")
+	useKeys(t, "1:"+testKey1+",2:"+testKey2, "")
+	current, _ := Encrypt("张三")
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"empty", "", false},
+		{"plaintext", "张三", true},
+		{"old version", old, true},
+		{"current version", current, false},
+	}
+	for _, tt := range tests {
+		if got := NeedsRotation(tt.value); got != tt.want {
+			t.Errorf("%s: NeedsRotation(%q) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecryptLegacyPlaintext(t *testing.T) {
+	useKeys(t, "1:"+testKey1, "")
+	for _, value := range []string{"张三", "2023000000", "v", "vx:abc", "version"} {
+		got, err := Decrypt(value)
+		if err != nil || got != value {
+			t.Errorf("Decrypt(%q) = %q, %v, want passthrough", value, got, err)
+		}
+	}
+	if _, err := Decrypt("v1:not-base64!"); err == nil {
+		t.Error("Decrypt of malformed ciphertext should fail")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	useKeys(t, "1:"+testKey1, "")
+	if BlindIndex("") != "" {
+		t.Error("BlindIndex(\"\") should be empty")
+	}
+	first := BlindIndex("2023000000")
+	if first != BlindIndex("2023000000") {
+		t.Error("BlindIndex is not stable")
+	}
+	if first == BlindIndex("2023000001") {
+		t.Error("BlindIndex collides for different values")
+	}
+	// 轮换加密密钥不影响盲索引
+	useKeys(t, "1:"+testKey1+",2:"+testKey2, "")
+	if BlindIndex("2023000000") != first {
+		t.Error("BlindIndex changed after rotating encryption keys")
+	}
+}
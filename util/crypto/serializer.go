@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// EncryptedSerializer 是透明加密字符串字段的GORM序列化器，
+// 使用方式为在字段上标注`gorm:"serializer:encrypted"`。
+// 加密后的值无法直接用于查询，需要查询的字段应另外保存BlindIndex。
+type EncryptedSerializer struct{}
+
+// Init 加载密钥并注册序列化器，需要在迁移数据库之前调用。
+func Init() {
+	loadKeys()
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// Scan 解密数据库中的值。
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("无法解密字段%s：不支持的类型%T", field.Name, dbValue)
+	}
+	plaintext, err := Decrypt(value)
+	if err != nil {
+		return err
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value 加密将要写入数据库的值。
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("无法加密字段%s：不支持的类型%T", field.Name, fieldValue)
+	}
+	return Encrypt(value)
+}
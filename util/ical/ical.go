@@ -0,0 +1,108 @@
+package ical
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType 是iCalendar文件的MIME类型。
+const ContentType = "text/calendar; charset=utf-8"
+
+// maxLineOctets 是RFC 5545规定的每行最大字节数，不含换行符。
+const maxLineOctets = 75
+
+// Calendar 是一个iCalendar日历。
+type Calendar struct {
+	// Name 是日历的名称，显示在订阅日历的客户端中。
+	Name string
+	// Events 是日历中的事件。
+	Events []Event
+}
+
+// Event 是日历中的一个事件，时间以UTC输出。
+type Event struct {
+	// Uid 是事件的全局唯一标识符，重复导入时客户端据此更新而不是新建事件。
+	Uid string
+	// Summary 是事件的标题。
+	Summary string
+	// Description 是事件的描述。
+	Description string
+	// Location 是事件的地点。
+	Location string
+	// Start 是事件的开始时间。
+	Start time.Time
+	// End 是事件的结束时间。
+	End time.Time
+	// UpdatedAt 是事件最后修改的时间。
+	UpdatedAt time.Time
+}
+
+// String 将日历序列化为iCalendar格式。
+func (c *Calendar) String() string {
+	var b strings.Builder
+	writeLine(&b, "BEGIN:VCALENDAR")
+	writeLine(&b, "VERSION:2.0")
+	writeLine(&b, "PRODID:-//E-Lab//elab-backend//ZH")
+	writeLine(&b, "CALSCALE:GREGORIAN")
+	writeLine(&b, "METHOD:PUBLISH")
+	if c.Name != "" {
+		writeLine(&b, "X-WR-CALNAME:"+escape(c.Name))
+	}
+	now := time.Now()
+	for _, e := range c.Events {
+		stamp := e.UpdatedAt
+		if stamp.IsZero() {
+			stamp = now
+		}
+		writeLine(&b, "BEGIN:VEVENT")
+		writeLine(&b, "UID:"+escape(e.Uid))
+		writeLine(&b, "DTSTAMP:"+formatTime(stamp))
+		writeLine(&b, "DTSTART:"+formatTime(e.Start))
+		writeLine(&b, "DTEND:"+formatTime(e.End))
+		writeLine(&b, "SUMMARY:"+escape(e.Summary))
+		if e.Location != "" {
+			writeLine(&b, "LOCATION:"+escape(e.Location))
+		}
+		if e.Description != "" {
+			writeLine(&b, "DESCRIPTION:"+escape(e.Description))
+		}
+		writeLine(&b, "END:VEVENT")
+	}
+	writeLine(&b, "END:VCALENDAR")
+	return b.String()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape 转义文本值中的特殊字符。
+func escape(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// writeLine 写入一行内容，超过75字节时按RFC 5545折行，不会拆开多字节字符。
+func writeLine(b *strings.Builder, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// 续行开头的空格占用一个字节
+		limit = maxLineOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
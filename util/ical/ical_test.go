@@ -0,0 +1,36 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"short", "SUMMARY:面试", "SUMMARY:面试\r\n"},
+		{"exactly 75 octets", strings.Repeat("a", 75), strings.Repeat("a", 75) + "\r\n"},
+		{"76 octets", strings.Repeat("a", 76), strings.Repeat("a", 75) + "\r\n a\r\n"},
+		{"continuation limit", strings.Repeat("a", 150), strings.Repeat("a", 75) + "\r\n " + strings.Repeat("a", 74) + "\r\n a\r\n"},
+		// 每个汉字占3个字节，第25个汉字结束于第75个字节，第26个不能被拆开
+		{"multibyte", strings.Repeat("中", 26), strings.Repeat("中", 25) + "\r\n 中\r\n"},
+		{"multibyte offset", "a" + strings.Repeat("中", 25), "a" + strings.Repeat("中", 24) + "\r\n 中\r\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		writeLine(&b, tt.line)
+		got := b.String()
+		if got != tt.want {
+			t.Errorf("%s: writeLine() = %q, want %q", tt.name, got, tt.want)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+			if len(line) > maxLineOctets || !utf8.ValidString(line) {
+				t.Errorf("%s: invalid folded line %q", tt.name, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package markdown
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"log/slog"
+)
+
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+var policy = bluemonday.UGCPolicy()
+
+// Render 将Markdown渲染为经过清洗的安全HTML。
+//
+// source 是Markdown原文。
+func Render(source string) string {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	err := renderer.Convert([]byte(source), &buf)
+	if err != nil {
+		slog.Error("util.markdown.Render: 无法渲染Markdown", "error", err)
+		return policy.Sanitize(source)
+	}
+	return policy.SanitizeReader(&buf).String()
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"elab-backend/service"
+	"elab-backend/service/mail"
+	"elab-backend/util/request"
+	"embed"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	// EventTicketSubmitted 是首次提交申请表的通知。
+	EventTicketSubmitted = "ticket_submitted"
+	// EventTextFormCompleted 是回答完文本表单全部问题的通知。
+	EventTextFormCompleted = "textform_completed"
+	// EventRoomSelected 是选择或更改面试房间的通知。
+	EventRoomSelected = "room_selected"
+	// EventInterviewReminder 是面试即将开始的提醒。
+	EventInterviewReminder = "interview_reminder"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	templates     map[string]*template.Template
+	templatesOnce sync.Once
+)
+
+type NoRecipientError struct{}
+
+func (e *NoRecipientError) Error() string {
+	return "用户没有可用的邮箱地址"
+}
+
+// getTemplate 获取某种语言下某个事件的模板，没有该语言的模板时使用中文模板。
+func getTemplate(language string, event string) (*template.Template, error) {
+	templatesOnce.Do(func() {
+		templates = make(map[string]*template.Template)
+		for _, language := range []string{request.LanguageChinese, request.LanguageEnglish} {
+			entries, err := templateFS.ReadDir("templates/" + language)
+			if err != nil {
+				panic(err)
+			}
+			for _, entry := range entries {
+				name := language + "/" + strings.TrimSuffix(entry.Name(), ".tmpl")
+				templates[name] = template.Must(template.ParseFS(templateFS, "templates/"+language+"/"+entry.Name()))
+			}
+		}
+	})
+	if t, ok := templates[language+"/"+event]; ok {
+		return t, nil
+	}
+	if t, ok := templates[request.LanguageChinese+"/"+event]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("通知模板不存在：%s", event)
+}
+
+// Render 渲染通知邮件的主题与正文。
+//
+// language 是收件人的语言。
+// event 是通知的事件。
+// data 是模板中使用的数据。
+func Render(language string, event string, data interface{}) (*mail.Message, error) {
+	t, err := getTemplate(language, event)
+	if err != nil {
+		return nil, err
+	}
+	var subject, body bytes.Buffer
+	err = t.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+	err = t.ExecuteTemplate(&body, "body", data)
+	if err != nil {
+		return nil, err
+	}
+	return &mail.Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    strings.TrimSpace(body.String()) + "\n",
+	}, nil
+}
+
+// ResolveAddress 获取用户的邮箱地址。
+// 联系方式为邮箱时直接使用，否则使用Auth0中登记的已验证邮箱。
+//
+// ctx 是上下文。
+// openid 是用户的Openid。
+// contact 是申请表中的联系方式。
+func ResolveAddress(ctx context.Context, openid string, contact string) (string, error) {
+	if strings.Contains(contact, "@") {
+		return contact, nil
+	}
+	srv := service.GetService()
+	user, err := srv.AuthAPI.User.Read(ctx, openid)
+	if err != nil {
+		return "", err
+	}
+	if user.GetEmail() == "" || !user.GetEmailVerified() {
+		return "", &NoRecipientError{}
+	}
+	return user.GetEmail(), nil
+}
+
+// Deliver 渲染并发送一封通知邮件。
+//
+// ctx 是上下文。
+// openid 是收件人的Openid。
+// contact 是收件人申请表中的联系方式。
+// language 是收件人的语言。
+// event 是通知的事件。
+// data 是模板中使用的数据。
+func Deliver(ctx context.Context, openid string, contact string, language string, event string, data interface{}) error {
+	msg, err := Render(language, event, data)
+	if err != nil {
+		return err
+	}
+	msg.To, err = ResolveAddress(ctx, openid, contact)
+	if err != nil {
+		return err
+	}
+	srv := service.GetService()
+	return srv.Mail.Send(ctx, msg)
+}
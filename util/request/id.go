@@ -0,0 +1,63 @@
+package request
+
+import (
+	"context"
+	"github.com/google/uuid"
+)
+
+// IdKey 是请求ID在gin上下文中的键。
+const IdKey = "request_id"
+
+// IdHeader 是携带请求ID的HTTP头。
+const IdHeader = "X-Request-Id"
+
+// NewRequestId 生成一个新的请求ID。
+func NewRequestId() string {
+	return uuid.NewString()
+}
+
+// GetRequestId 获取当前请求的请求ID。
+//
+// ctx 是上下文，通常为 *gin.Context。
+func GetRequestId(ctx context.Context) string {
+	id, ok := ctx.Value(IdKey).(string)
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// ActorKey 是当前登录用户的Subject在gin上下文中的键。
+const ActorKey = "actor"
+
+// GetActor 获取执行当前请求的用户的Subject，没有登录用户时返回空字符串。
+//
+// ctx 是上下文，通常为 *gin.Context。
+func GetActor(ctx context.Context) string {
+	actor, ok := ctx.Value(ActorKey).(string)
+	if !ok {
+		return ""
+	}
+	return actor
+}
+
+// LanguageKey 是客户端首选语言在gin上下文中的键。
+const LanguageKey = "language"
+
+const (
+	// LanguageChinese 是简体中文。
+	LanguageChinese = "zh"
+	// LanguageEnglish 是英文。
+	LanguageEnglish = "en"
+)
+
+// GetLanguage 获取客户端的首选语言，为LanguageChinese或LanguageEnglish，默认为中文。
+//
+// ctx 是上下文，通常为 *gin.Context。
+func GetLanguage(ctx context.Context) string {
+	language, ok := ctx.Value(LanguageKey).(string)
+	if !ok || language == "" {
+		return LanguageChinese
+	}
+	return language
+}
@@ -0,0 +1,82 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	key     []byte
+	keyOnce sync.Once
+)
+
+type InvalidSignatureError struct{}
+
+func (e *InvalidSignatureError) Error() string {
+	return "签名无效"
+}
+
+type SignatureExpiredError struct{}
+
+func (e *SignatureExpiredError) Error() string {
+	return "链接已过期"
+}
+
+// getKey 获取签名密钥，由URL_SIGNING_KEY指定。
+// 未指定时使用随机密钥，此时签名仅在当前进程内有效。
+func getKey() []byte {
+	keyOnce.Do(func() {
+		value := os.Getenv("URL_SIGNING_KEY")
+		if value != "" {
+			key = []byte(value)
+			return
+		}
+		slog.Warn("util.sign.getKey: URL_SIGNING_KEY为空，使用随机密钥，签名在重启或多副本间不可用")
+		key = make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			slog.Error("无法生成随机密钥", "error", err)
+			panic(err)
+		}
+	})
+	return key
+}
+
+func compute(resource string, expires int64) string {
+	mac := hmac.New(sha256.New, getKey())
+	mac.Write([]byte(resource))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign 为资源生成带有过期时间的签名。
+//
+// resource 是被签名的资源。
+// expires 是过期时间。
+func Sign(resource string, expires time.Time) string {
+	return compute(resource, expires.Unix())
+}
+
+// Verify 验证资源的签名。
+//
+// resource 是被签名的资源。
+// expires 是签名中的过期时间，为Unix时间戳。
+// signature 是签名。
+func Verify(resource string, expires int64, signature string) error {
+	expected := compute(resource, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return &InvalidSignatureError{}
+	}
+	if time.Now().Unix() > expires {
+		return &SignatureExpiredError{}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// FieldError 是字段级别的校验错误。
+type FieldError struct {
+	// Fields 的键是JSON字段名，值是错误描述。
+	Fields map[string]string
+}
+
+func (e *FieldError) Error() string {
+	return "请求格式错误"
+}
+
+var (
+	phonePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+	emailPattern = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}$`)
+	// studentIdPattern 是学号的格式，可以通过TICKET_STUDENT_ID_PATTERN修改。
+	studentIdPattern = regexp.MustCompile(`^[0-9A-Za-z]{6,16}$`)
+)
+
+// Init 向gin的校验器注册自定义规则，并使用JSON字段名报告错误。
+func Init() {
+	slog.Debug("util.validate.Init: 正在注册校验规则")
+	if pattern := os.Getenv("TICKET_STUDENT_ID_PATTERN"); pattern != "" {
+		studentIdPattern = regexp.MustCompile(pattern)
+	}
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		panic("无法获取校验器")
+	}
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form", "uri"} {
+			name := strings.Split(field.Tag.Get(tag), ",")[0]
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return field.Name
+	})
+	mustRegister(engine, "student_id", func(fl validator.FieldLevel) bool {
+		return studentIdPattern.MatchString(fl.Field().String())
+	})
+	mustRegister(engine, "contact", func(fl validator.FieldLevel) bool {
+		value := fl.Field().String()
+		return phonePattern.MatchString(value) || emailPattern.MatchString(value)
+	})
+}
+
+func mustRegister(engine *validator.Validate, tag string, fn validator.Func) {
+	err := engine.RegisterValidation(tag, fn)
+	if err != nil {
+		slog.Error("无法注册校验规则", "tag", tag, "error", err)
+		panic(err)
+	}
+}
+
+// Struct 使用与请求绑定相同的规则校验结构体。
+//
+// v 是需要校验的结构体指针。
+func Struct(v any) error {
+	return Translate(binding.Validator.ValidateStruct(v))
+}
+
+// Translate 将校验器的错误转换为FieldError，其他错误原样返回。
+//
+// err 是绑定或校验返回的错误。
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	result := &FieldError{Fields: make(map[string]string, len(errs))}
+	for _, e := range errs {
+		result.Fields[e.Field()] = message(e)
+	}
+	return result
+}
+
+func message(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "不能为空"
+	case "max":
+		return fmt.Sprintf("长度不能超过%s个字符", e.Param())
+	case "min":
+		return fmt.Sprintf("长度不能少于%s个字符", e.Param())
+	case "oneof":
+		return "取值无效"
+	case "student_id":
+		return "学号格式不正确"
+	case "contact":
+		return "请填写正确的手机号或邮箱"
+	}
+	return "格式不正确"
+}
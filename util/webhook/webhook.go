@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"elab-backend/util/config"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderEvent 是事件类型的请求头。
+	HeaderEvent = "X-Elab-Event"
+	// HeaderDelivery 是投递ID的请求头，重新投递时保持不变，可用于去重。
+	HeaderDelivery = "X-Elab-Delivery"
+	// HeaderTimestamp 是签名时间的请求头，为Unix时间戳。
+	HeaderTimestamp = "X-Elab-Timestamp"
+	// HeaderSignature 是签名的请求头，格式为“sha256=<hex>”。
+	HeaderSignature = "X-Elab-Signature"
+)
+
+// maxResponseBody 是投递记录中保存的响应正文的最大长度。
+const maxResponseBody = 1024
+
+// Result 是一次投递的结果。
+type Result struct {
+	// StatusCode 是响应的状态码，请求未发出时为0。
+	StatusCode int
+	// Body 是响应正文的前1024个字节。
+	Body string
+	// Duration 是请求的耗时。
+	Duration time.Duration
+}
+
+type UnexpectedStatusError struct {
+	// StatusCode 是响应的状态码。
+	StatusCode int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return "接收方返回了状态码" + strconv.Itoa(e.StatusCode)
+}
+
+// NewSecret 生成一个新的签名密钥。
+func NewSecret() string {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	return "whsec_" + hex.EncodeToString(buf)
+}
+
+// Sign 计算请求的签名。
+// 签名的内容为“<timestamp>.<body>”，接收方应当使用相同的方式计算并比较签名，并拒绝时间相差过大的请求。
+//
+// secret 是端点的签名密钥。
+// timestamp 是签名时间的Unix时间戳。
+// body 是请求正文。
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send 向端点发送一次签名后的POST请求，2xx以外的状态码视为失败。
+// 超时时间由WEBHOOK_TIMEOUT指定，默认为10秒。
+//
+// ctx 是上下文。
+// url 是端点的地址。
+// secret 是端点的签名密钥。
+// eventType 是事件的类型。
+// deliveryId 是投递ID。
+// body 是请求正文，为JSON。
+func Send(ctx context.Context, url string, secret string, eventType string, deliveryId string, body []byte) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.GetDuration("WEBHOOK_TIMEOUT", 10*time.Second))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &Result{}, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "elab-backend-webhook")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryId)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	result := &Result{Duration: time.Since(start)}
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return result, fmt.Errorf("读取响应失败：%w", err)
+	}
+	result.Body = string(content)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return result, &UnexpectedStatusError{StatusCode: resp.StatusCode}
+	}
+	return result, nil
+}
@@ -0,0 +1,25 @@
+package webhook
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"body", "whsec_test", 1700000000, `{"a":1}`, "sha256=38877139021993b830af32feea6e18a8da83eb2f6e49ee50bd9e4cf4ca4d3789"},
+		{"empty body", "whsec_test", 1700000000, "", "sha256=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+		{"other secret", "other", 1700000000, `{"a":1}`, "sha256=2cb38bd50b3aa61b12df512da616c9577f2a99edb9467110d361a655e1ad3bd5"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: Sign() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if Sign("whsec_test", 1700000000, []byte("x")) == Sign("whsec_test", 1700000001, []byte("x")) {
+		t.Error("Sign does not cover the timestamp")
+	}
+}